@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	scmp "github.com/GrmlForensic/screenshot-compare/v1"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+// BATCH_USAGE for CLI command batch
+const BATCH_USAGE = `PARAMETERS
+
+  batch [<any parameter of the comparison>]
+  <manifest>
+
+DESCRIPTION
+
+  Run many named comparisons in parallel. <manifest> is a JSON file like
+
+    {
+      "defaults": {"colors": "Y'UV", "threshold": 0.05},
+      "comparisons": [
+        {"name": "grub", "baseimg": "shots/grub.png", "refimg": "refs/grub.png",
+         "diffpixel": 10},
+        {"name": "login", "baseimg": "shots/login.png", "refimg": "refs/login.png",
+         "mask": "masks/login.png"}
+      ]
+    }
+
+  "defaults" and every comparison accept the keys of the JSON configuration
+  file. Options of a comparison override "defaults", which override the
+  parameters of the command line. "mask" is an image whose alpha channel
+  is applied to the reference image. Relative filepaths are relative to
+  the directory of <manifest>.
+  Run screenshot-compare without command for all other parameters.
+
+EXIT CODE
+
+  0 if all comparisons match, 100 if any comparison does not match
+  or fails and 101 for any runtime error.
+`
+
+// runBatch implements CLI command batch
+func runBatch(args []string) {
+	cli := kingpin.New(args[0], BATCH_USAGE)
+	flags := scmp.RegisterFlags(cli)
+	manifestFile := cli.Arg("manifest", `JSON file listing the comparisons`).Required().String()
+	showPotentialCLIError(BATCH_USAGE, scmp.ParseArgs(cli, args[1:]))
+	conf := readOptions(flags, BATCH_USAGE)
+
+	var manifest scmp.Manifest
+	showPotentialCLIError(BATCH_USAGE, manifest.FromFile(*manifestFile, conf))
+
+	result := scmp.BatchResult{}
+	if err := scmp.RunBatch(&manifest, &result); err != nil {
+		fmt.Fprintf(os.Stderr, "\n\033[1merror:\033[0m "+err.Error()+"\n")
+		os.Exit(101)
+	}
+
+	fmt.Printf("runtime:                %s\n", result.Runtime)
+	for _, c := range result.Comparisons {
+		if c.Err != nil {
+			fmt.Printf("%-23s error: %s\n", c.Name+":", c.Err)
+		} else {
+			fmt.Printf("%-23s %.3f %% (match %t)\n", c.Name+":", 100*c.Score, c.Match)
+		}
+	}
+	fmt.Printf("failed:                 %d of %d\n", result.Failed, len(result.Comparisons))
+	if !result.Passed() {
+		os.Exit(100)
+	}
+}
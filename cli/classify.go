@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	scmp "github.com/GrmlForensic/screenshot-compare/v1"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+// CLASSIFY_USAGE for CLI command classify
+const CLASSIFY_USAGE = `PARAMETERS
+
+  classify [<any parameter of the comparison>]
+  <base> <ref> [<ref> ...]
+
+DESCRIPTION
+
+  Classify a screenshot: <base> is compared with every <ref> concurrently
+  and the references are ranked by their difference percentage.
+  The class is the best-ranked reference if it matches <base>
+  (see --threshold and --metrics) and "no match" otherwise.
+
+  --timeout <duration> applies to every single comparison.
+  Run screenshot-compare without command for all other parameters.
+
+EXIT CODE
+
+  0 if the best-ranked reference matches. Otherwise the exit code is
+  the difference percentage of the best-ranked reference (at least 1),
+  101 for any runtime error and 102 if a comparison exceeded --timeout.
+`
+
+// runClassify implements CLI command classify
+func runClassify(args []string) {
+	cli := kingpin.New(args[0], CLASSIFY_USAGE)
+	flags := scmp.RegisterFlags(cli)
+	base := cli.Arg("base", `image to classify`).Required().String()
+	refLocations := cli.Arg("ref", `reference images`).Required().Strings()
+	showPotentialCLIError(CLASSIFY_USAGE, scmp.ParseArgs(cli, args[1:]))
+	conf := readOptions(flags, CLASSIFY_USAGE)
+
+	err := conf.LoadImage(&conf.BaseImg, *base)
+	showPotentialCLIError(CLASSIFY_USAGE, err)
+	refs := make([]scmp.TaggedImage, len(*refLocations))
+	for k, location := range *refLocations {
+		err := conf.LoadImage(&refs[k], location)
+		showPotentialCLIError(CLASSIFY_USAGE, err)
+	}
+
+	result := scmp.ClassifyResult{}
+	err = scmp.Classify(conf, refs, &result)
+	if err != nil && !result.Timeout {
+		fmt.Fprintf(os.Stderr, "\n\033[1merror:\033[0m "+err.Error()+"\n")
+		os.Exit(101)
+	} else if result.Timeout {
+		fmt.Fprintf(os.Stderr, "\n\033[1merror:\033[0m "+err.Error()+"\n")
+		os.Exit(102)
+	}
+
+	fmt.Printf("runtime:                %s\n", result.Runtime)
+	for k, c := range result.Ranking {
+		fmt.Printf("%-23s %.3f %% %s (match %t)\n", fmt.Sprintf("rank %d:", k+1), 100*c.Score, c.Ref, c.Match)
+	}
+	if best := result.Best(); best != nil {
+		fmt.Printf("class:                  %s\n", best.Ref)
+	} else {
+		fmt.Printf("class:                  no match\n")
+	}
+	exitWithMatch(&result.Ranking[0].Result)
+}
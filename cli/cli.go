@@ -4,19 +4,39 @@ import (
 	"fmt"
 	"os"
 
+	_ "github.com/GrmlForensic/screenshot-compare/source"
 	scmp "github.com/GrmlForensic/screenshot-compare/v1"
 )
 
 // USAGE for CLI
 const USAGE = `PARAMETERS
 
-  [--colors <colorspace> | --timeout <duration> | --wait <duration>
-  | --diffpixel <count> | --nodimerror] <base> <ref>
+  [--colors <colorspace> | --luma <standard> | --timeout <duration> | --wait <duration>
+  | --diffpixel <count> | --nodimerror | --linear | --frames | --orient | --icc
+  | --threshold <score> | --alpha <mode> | --background <color> | --metrics <metrics>
+  | --raw-format <format> | --raw-size <size> | --raw-stride <bytes>
+  | --pending <directory>]
+  <base> <ref>
+
+  screenshot-compare <command> [<parameters>]
 
 DESCRIPTION
 
   Compare two images and quantify their difference.
 
+COMMANDS
+
+  wait      compare repeatedly until the images match (see 'wait --help')
+  classify  rank many references by their similarity (see 'classify --help')
+  states    verify a sequence of screen states (see 'states --help')
+  batch     run many comparisons of a manifest file (see 'batch --help')
+  dir       compare two directories of screenshots (see 'dir --help')
+  matrix    compute the scores of all pairs of images (see 'matrix --help')
+  cluster   group screenshots into distinct screens (see 'cluster --help')
+  review    list failed comparisons recorded by --pending (see 'review --help')
+  approve   promote recorded screenshots to references (see 'approve --help')
+  remask    transfer the mask of a reference onto a screenshot (see 'remask --help')
+
 DURATION
 
   <duration> matches '\d+[ismh]'
@@ -27,10 +47,16 @@ DURATION
 
 OPTIONS
 
-  --colors <colorspace> ∈ {"RGB", "Y'UV"} with default value "RGB"
+  --colors <colorspace> ∈ {"RGB", "Y'UV", "Gray", "R", "G", "B", "Y"}
+  with default value "RGB"
     RGB is the standard color model.
     "Y'UV" resembles the perception of the colors by the eye better.
     Hence the differences better quantify the visual differences.
+    "Gray" ignores chroma and only compares luma. "R", "G", "B" and "Y"
+    compare a single channel of RGB or Y'UV respectively.
+
+  --luma <standard> ∈ {"BT.601", "BT.709"} with default value "BT.601"
+    Defines the weights of the color channels to compute luma for "Gray".
 
   --timeout <duration> with default value "0s"
     Assigns a maximum runtime for the comparison algorithm.
@@ -47,11 +73,89 @@ OPTIONS
     if true and dimensions of the images do not match, returns difference
     set to maximum. if false, return error with exit code 101.
 
+  --linear with default value false
+    if true, gamma-encoded sRGB values are converted to linear light
+    before color space conversion and distance computation.
+
+  --frames with default value false
+    if true, all frames of animated GIF and APNG images are compared.
+    Every base frame is compared with every reference frame and the
+    frame sequences are aligned, so they may differ in length. The
+    score is the mean score of the aligned frames; the images match
+    if every aligned pair of frames matches. Still images are
+    considered animations with a single frame.
+
+  --orient with default value false
+    if true, JPEG and PNG images are rotated and flipped according to
+    their EXIF orientation before comparison.
+
+  --icc with default value false
+    if true, JPEG and PNG images with an embedded Display P3 or
+    Adobe RGB color profile are converted to sRGB before comparison.
+
+  --threshold <score> with default value "0.1"
+    A number between 0 and 1. Images with a score not exceeding
+    this threshold are reported as matching. "0" only accepts images
+    without any difference.
+
+  --alpha <mode> with default value "ref-mask"
+    Defines how alpha channels are considered. One of
+      "ref-mask"                   alpha of <ref> weights each pixel
+      "both-mask"                  alpha of <base> and <ref> multiplied
+                                   weights each pixel
+      "composite-over-background"  flatten both images over --background
+      "compare-alpha-as-channel"   compare alpha like a color channel
+
+  --background <color> with default value "#ffffff"
+    Background color for alpha mode "composite-over-background".
+    Matches '#rrggbb' or '#rrggbbaa' with alpha 'ff' (opaque).
+
+  --metrics <metrics> with default value ""
+    Comma-separated list of metrics to evaluate in one run. Each metric
+    matches 'name[:colorspace[:weight[:threshold]]]' with name one of
+    "pixel", "ssim" and "histogram". The score is the weighted mean
+    of the metric scores. Images match if every metric matches.
+    Example: 'pixel:RGB:2:0.05,ssim:::0.2,histogram'
+
+  --raw-format <format> with default value ""
+    Pixel format of images given as 'raw:<filepath>' (e.g. 'raw:/dev/fb0').
+    One of RGB565, BGR565, XRGB1555, ARGB1555, RGB888, BGR888, XRGB8888,
+    ARGB8888, XBGR8888, ABGR8888, RGBX8888, RGBA8888, BGRX8888, BGRA8888.
+    Names follow the Linux DRM fourcc formats, i.e. components are listed
+    from the most to the least significant bit of a little-endian word.
+
+  --raw-size <size> matches '\d+x\d+'
+    Width and height of raw framebuffer dumps, e.g. '1024x768'.
+
+  --raw-stride <bytes> with default value width × bytes per pixel
+    Number of bytes per row of raw framebuffer dumps.
+
+  --pending <directory> with default value ""
+    If the images do not match, <base>, a diff image and the score are
+    recorded in a subdirectory of <directory> named like <ref>, so the
+    change can be inspected with 'review' and promoted to <ref> with
+    'approve'. <ref> must be a PNG file then.
+
   <base> is a required positional argument
-    is a filepath to the base image (alpha channel is ignored)
+    is a filepath to the base image (alpha channel is ignored by default)
+    or "-" to read the image from standard input
+    or 'raw:<filepath>' to read a raw framebuffer dump
+    or '<archive>!/<member>' to read a member of a zip or tar archive
+    (.zip, .tar, .tar.gz, .tgz), e.g. 'refs.tar.gz!/grub/menu.png'
+    or 'vnc://[:password@]host[:port]' to capture the screen of a VNC server
+    (password defaults to the environment variable SCMP_VNC_PASSWORD)
+    or 'qmp:///path/to/socket' to take a screendump of a QEMU instance via QMP
+    or an 'http://' or 'https://' URL (additional request headers like
+    'Authorization: Bearer <token>' are read from the environment variable
+    SCMP_HTTP_HEADER, separated by newlines)
 
   <ref> is a required positional argument
-    is a filepath to the reference image (alpha channel represents transparency)
+    is a filepath to the reference image (alpha channel represents transparency
+    by default) or "-" to read the image from standard input
+    or any other location accepted by <base>
+
+  Supported image formats are PNG, JPEG, GIF, BMP, TIFF, WebP and
+  Netpbm (PBM, PGM, PPM).
 
 REMARKS
 
@@ -67,46 +171,144 @@ EXIT CODE
     102   timeout reached
 `
 
-func showPotentialCLIError(err error) {
+// commands maps names of subcommands to their implementation.
+// Each implementation receives the CLI arguments with the command name appended to the program name
+var commands = map[string]func(args []string){
+	"wait":     runWait,
+	"classify": runClassify,
+	"states":   runStates,
+	"batch":    runBatch,
+	"dir":      runDir,
+	"matrix":   runMatrix,
+	"cluster":  runCluster,
+	"review":   runReview,
+	"approve":  runApprove,
+	"remask":   runRemask,
+}
+
+func showPotentialCLIError(usage string, err error) {
 	if err != nil {
-		fmt.Fprintf(os.Stderr, USAGE)
+		fmt.Fprintf(os.Stderr, usage)
 		fmt.Fprintf(os.Stderr, "\n\033[1merror:\033[0m "+err.Error()+"\n")
 		os.Exit(101)
 	}
 }
 
-func main() {
+// readConfig reads the configuration from environment variables, JSON file
+// and CLI arguments (in increasing precedence) and exits on errors
+func readConfig(args []string, usage string) *scmp.Config {
 	conf := scmp.NewConfig()
-	result := scmp.Result{}
 
 	_, errEnv := conf.FromEnv(2)
-	showPotentialCLIError(errEnv)
+	showPotentialCLIError(usage, errEnv)
 	_, errJSON := conf.FromJSON("", true, 2)
-	showPotentialCLIError(errJSON)
-	_, errArgs := conf.FromArgs(os.Args, USAGE, 2)
-	showPotentialCLIError(errArgs)
+	showPotentialCLIError(usage, errJSON)
+	_, errArgs := conf.FromArgs(args, usage, 2)
+	showPotentialCLIError(usage, errArgs)
 
 	// even though Valid() is called within Compare, we want
 	// to ensure it is represented as CLI error
-	showPotentialCLIError(conf.Valid())
+	showPotentialCLIError(usage, conf.Valid())
+	return conf
+}
 
-	// image comparison
-	err := scmp.Compare(conf, &result)
-	if err != nil && !result.Timeout {
-		fmt.Fprintf(os.Stderr, "\n\033[1merror:\033[0m "+err.Error()+"\n")
-		os.Exit(101)
-	}
+// readOptions reads the comparison options of a command from environment variables,
+// JSON file and CLI flags (in increasing precedence) and exits on errors.
+// Only options which are set override options of a source with lower precedence.
+// Images given by SCMP_BASEIMG, SCMP_REFIMG or the JSON file are ignored; the command loads its own images
+func readOptions(flags *scmp.ConfigFlags, usage string) *scmp.Config {
+	conf := scmp.NewConfig()
 
-	// wait for result (either timeout or result)
+	_, errEnv := conf.OptionsFromEnv()
+	showPotentialCLIError(usage, errEnv)
+	_, errJSON := conf.OptionsFromJSON("", true)
+	showPotentialCLIError(usage, errJSON)
+	_, errFlags := conf.FromFlags(flags, 3)
+	showPotentialCLIError(usage, errFlags)
+
+	showPotentialCLIError(usage, conf.ValidOptions())
+	return conf
+}
+
+// printResult prints the human-readable comparison result
+func printResult(conf *scmp.Config, result *scmp.Result) {
 	percent := float64(100 * result.Score)
 	fmt.Printf("runtime:                %s\n", result.Runtime)
 	fmt.Printf("timeout:                %t\n", result.Timeout)
 	fmt.Printf("pixels different:       %d\n", result.PixelsDifferent)
+	fmt.Printf("format (base/ref):      %s/%s\n", result.BaseFormat, result.RefFormat)
+	fmt.Printf("bit depth (base/ref):   %d/%d\n", result.BaseBitDepth, result.RefBitDepth)
 	fmt.Printf("difference percentage:  %.3f %%\n", percent)
+	for _, m := range result.Metrics {
+		fmt.Printf("%-23s %.3f %% (weight %g, match %t)\n", m.Name+":", 100*m.Score, m.Weight, m.Match)
+	}
+	for _, f := range result.Frames {
+		fmt.Printf("%-23s %.3f %% (match %t)\n", fmt.Sprintf("frame %d/%d:", f.BaseFrame, f.RefFrame), 100*f.Score, f.Match)
+	}
+	if conf.Frames {
+		fmt.Printf("best frame (base/ref):  %d/%d with %.3f %%\n", result.BestFrame.BaseFrame, result.BestFrame.RefFrame, 100*result.BestFrame.Score)
+	}
+	fmt.Printf("match:                  %t\n", result.Match)
+}
+
+// recordPending records a failed comparison for approval if --pending is given and exits on errors
+func recordPending(conf *scmp.Config, result *scmp.Result) {
+	if result.Timeout {
+		return
+	}
+	if err := scmp.RecordPending(conf, result, ""); err != nil {
+		fmt.Fprintf(os.Stderr, "\n\033[1merror:\033[0m "+err.Error()+"\n")
+		os.Exit(101)
+	}
+}
 
+// exitWithResult terminates with the exit code representing the comparison result
+func exitWithResult(result *scmp.Result) {
 	if result.Timeout {
 		os.Exit(102)
 	} else {
-		os.Exit(int(percent))
+		os.Exit(int(100 * result.Score))
+	}
+}
+
+// exitWithMatch terminates with 0 if the images match and the exit code of exitWithResult otherwise.
+// Images which do not match never exit with 0, even if their difference percentage is below 1
+func exitWithMatch(result *scmp.Result) {
+	if result.Timeout {
+		os.Exit(102)
+	} else if result.Match {
+		os.Exit(0)
+	} else if code := int(100 * result.Score); code > 0 {
+		os.Exit(code)
 	}
+	os.Exit(1)
+}
+
+func main() {
+	if len(os.Args) > 1 {
+		if run, ok := commands[os.Args[1]]; ok {
+			run(append([]string{os.Args[0] + " " + os.Args[1]}, os.Args[2:]...))
+			return
+		}
+	}
+
+	conf := readConfig(os.Args, USAGE)
+	result := scmp.Result{}
+
+	// image comparison
+	compare := scmp.Compare
+	if conf.Frames {
+		compare = scmp.CompareFrames
+	}
+	err := compare(conf, &result)
+	if err != nil && !result.Timeout {
+		fmt.Fprintf(os.Stderr, "\n\033[1merror:\033[0m "+err.Error()+"\n")
+		os.Exit(101)
+	}
+
+	recordPending(conf, &result)
+
+	// wait for result (either timeout or result)
+	printResult(conf, &result)
+	exitWithResult(&result)
 }
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	scmp "github.com/GrmlForensic/screenshot-compare/v1"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+// CLUSTER_USAGE for CLI command cluster
+const CLUSTER_USAGE = `PARAMETERS
+
+  cluster [--method <method> | --format <format> | --output <filepath>
+  | <any parameter of the comparison>]
+  <image> [<image> ...]
+
+DESCRIPTION
+
+  Discover the distinct screens of many screenshots. Every pair of images
+  is compared (in parallel) and the images are grouped into clusters of
+  images within --threshold of each other. The distance of two images is
+  the maximum score of comparing them in both directions. The medoid of
+  every cluster is its representative. Files which cannot be read as
+  image are skipped.
+
+  --method <method> ∈ {"threshold", "hierarchical"} with default value "hierarchical"
+    "threshold" assigns every image to the first cluster whose first
+    image is within --threshold. "hierarchical" merges clusters as long
+    as all pairs of their images are within --threshold.
+
+  --format <format> ∈ {"text", "csv", "json"} with default value "text"
+    Format of the report mapping every image to its cluster.
+
+  --output <filepath> with default value ""
+    Write the report to the given file instead of standard output.
+
+  Use --metrics (e.g. 'ssim') for perceptual distances.
+  Run screenshot-compare without command for all other parameters.
+
+EXIT CODE
+
+  0 if the report was written and 101 for any runtime error.
+`
+
+// runCluster implements CLI command cluster
+func runCluster(args []string) {
+	cli := kingpin.New(args[0], CLUSTER_USAGE)
+	flags := scmp.RegisterFlags(cli)
+	method := cli.Flag("method", `cluster method, one of "threshold" and "hierarchical"`).Default("hierarchical").Enum(scmp.ClusterMethods...)
+	format := cli.Flag("format", `output format, one of "text", "csv" and "json"`).Default("text").Enum(scmp.ClusterFormats...)
+	output := cli.Flag("output", `write the report to the given file`).Short('o').String()
+	locations := cli.Arg("image", `images to cluster`).Required().Strings()
+	showPotentialCLIError(CLUSTER_USAGE, scmp.ParseArgs(cli, args[1:]))
+	conf := readOptions(flags, CLUSTER_USAGE)
+
+	result := scmp.ClusterResult{}
+	err := scmp.ClusterImages(conf, *locations, *method, &result)
+	if err == nil {
+		for _, s := range result.Skipped {
+			fmt.Fprintf(os.Stderr, "skipped %s: %s\n", s.Location, s.Err)
+		}
+		out := os.Stdout
+		if *output != "" {
+			out, err = os.Create(*output)
+		}
+		if err == nil {
+			err = result.Write(out, *format)
+			if errClose := out.Close(); err == nil {
+				err = errClose
+			}
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\n\033[1merror:\033[0m "+err.Error()+"\n")
+		os.Exit(101)
+	}
+}
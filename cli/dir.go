@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	scmp "github.com/GrmlForensic/screenshot-compare/v1"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+// DIR_USAGE for CLI command dir
+const DIR_USAGE = `PARAMETERS
+
+  dir [--report <filepath> | <any parameter of the comparison>]
+  <actual-dir> <expected-dir>
+
+DESCRIPTION
+
+  Compare a directory of new screenshots with a directory of golden ones.
+  Images are paired by their relative filepath (subdirectories included)
+  and every pair is compared in parallel with the same parameters.
+  Images in <expected-dir> missing in <actual-dir> and extra images in
+  <actual-dir> are reported. Files which are not images (by extension)
+  are ignored.
+
+  --report <filepath> with default value ""
+    Write a summary report as JSON to the given file.
+
+  Run screenshot-compare without command for all other parameters.
+
+EXIT CODE
+
+  0 if all pairs match and no image is missing or extra, 100 otherwise
+  and 101 for any runtime error.
+`
+
+// runDir implements CLI command dir
+func runDir(args []string) {
+	cli := kingpin.New(args[0], DIR_USAGE)
+	flags := scmp.RegisterFlags(cli)
+	report := cli.Flag("report", `write a summary report as JSON to the given file`).String()
+	actualDir := cli.Arg("actual-dir", `directory of new screenshots`).Required().String()
+	expectedDir := cli.Arg("expected-dir", `directory of golden screenshots`).Required().String()
+	showPotentialCLIError(DIR_USAGE, scmp.ParseArgs(cli, args[1:]))
+	conf := readOptions(flags, DIR_USAGE)
+
+	result := scmp.DirResult{}
+	if err := scmp.CompareDirs(conf, *actualDir, *expectedDir, &result); err != nil {
+		fmt.Fprintf(os.Stderr, "\n\033[1merror:\033[0m "+err.Error()+"\n")
+		os.Exit(101)
+	}
+
+	fmt.Printf("runtime:                %s\n", result.Runtime)
+	for _, c := range result.Comparisons {
+		if c.Err != nil {
+			fmt.Printf("%-23s error: %s\n", c.Name+":", c.Err)
+		} else {
+			fmt.Printf("%-23s %.3f %% (match %t)\n", c.Name+":", 100*c.Score, c.Match)
+		}
+	}
+	for _, rel := range result.Missing {
+		fmt.Printf("%-23s missing\n", rel+":")
+	}
+	for _, rel := range result.Extra {
+		fmt.Printf("%-23s extra\n", rel+":")
+	}
+	fmt.Printf("failed:                 %d of %d\n", result.Failed, len(result.Comparisons))
+	fmt.Printf("missing/extra:          %d/%d\n", len(result.Missing), len(result.Extra))
+
+	if *report != "" {
+		f, err := os.Create(*report)
+		if err == nil {
+			err = result.WriteJSON(f, *actualDir, *expectedDir)
+			if errClose := f.Close(); err == nil {
+				err = errClose
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "\n\033[1merror:\033[0m "+err.Error()+"\n")
+			os.Exit(101)
+		}
+	}
+	if !result.Passed() {
+		os.Exit(100)
+	}
+}
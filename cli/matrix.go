@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	scmp "github.com/GrmlForensic/screenshot-compare/v1"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+// MATRIX_USAGE for CLI command matrix
+const MATRIX_USAGE = `PARAMETERS
+
+  matrix [--format <format> | --output <filepath>
+  | <any parameter of the comparison>]
+  <image> [<image> ...]
+
+DESCRIPTION
+
+  Compute the score of every pair of images in parallel. Row i and
+  column j give the score of image i as base and image j as reference.
+  Files which cannot be read as image are skipped.
+
+  --format <format> ∈ {"adoc", "md", "csv", "json"} with default value "adoc"
+    "adoc" and "md" give percentages in a numbered table,
+    "csv" and "json" give scores between 0 and 1.
+
+  --output <filepath> with default value ""
+    Write the matrix to the given file instead of standard output.
+
+  Run screenshot-compare without command for all other parameters.
+
+EXIT CODE
+
+  0 if the matrix was written and 101 for any runtime error.
+`
+
+// runMatrix implements CLI command matrix
+func runMatrix(args []string) {
+	cli := kingpin.New(args[0], MATRIX_USAGE)
+	flags := scmp.RegisterFlags(cli)
+	format := cli.Flag("format", `output format, one of "adoc", "md", "csv" and "json"`).Default("adoc").Enum(scmp.MatrixFormats...)
+	output := cli.Flag("output", `write the matrix to the given file`).Short('o').String()
+	locations := cli.Arg("image", `images to compare`).Required().Strings()
+	showPotentialCLIError(MATRIX_USAGE, scmp.ParseArgs(cli, args[1:]))
+	conf := readOptions(flags, MATRIX_USAGE)
+
+	result := scmp.MatrixResult{}
+	err := scmp.ComputeMatrix(conf, *locations, &result)
+	if err == nil {
+		for _, s := range result.Skipped {
+			fmt.Fprintf(os.Stderr, "skipped %s: %s\n", s.Location, s.Err)
+		}
+		out := os.Stdout
+		if *output != "" {
+			out, err = os.Create(*output)
+		}
+		if err == nil {
+			err = result.Write(out, *format)
+			if errClose := out.Close(); err == nil {
+				err = errClose
+			}
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\n\033[1merror:\033[0m "+err.Error()+"\n")
+		os.Exit(101)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"os"
+
+	scmp "github.com/GrmlForensic/screenshot-compare/v1"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+// REMASK_USAGE for CLI command remask
+const REMASK_USAGE = `PARAMETERS
+
+  remask --output <filepath> [--align <pixels>
+  | <any parameter of the comparison>]
+  <new> <masked>
+
+DESCRIPTION
+
+  Transfer the alpha channel (mask) of the reference image <masked> onto
+  the screenshot <new> and write the result as PNG image, so a hand-made
+  mask survives replacing a reference image. The dimensions of <new> and
+  <masked> must correspond.
+
+  --output <filepath> is required
+    Filepath of the PNG image to write. May be <masked> itself.
+
+  --align <pixels> with default value "0"
+    If positive, the mask is moved by the offset of at most <pixels> pixels
+    in each direction at which the opaque area of <masked> matches <new>
+    best. Use it if the content of the screen moved slightly.
+
+  Run screenshot-compare without command for all other parameters.
+
+EXIT CODE
+
+  0 if the image was written and 101 for any runtime error.
+`
+
+// runRemask implements CLI command remask
+func runRemask(args []string) {
+	cli := kingpin.New(args[0], REMASK_USAGE)
+	flags := scmp.RegisterFlags(cli)
+	output := cli.Flag("output", `PNG image to write`).Short('o').Required().String()
+	align := cli.Flag("align", `maximum offset in pixels to realign the mask by`).Default("0").Int()
+	newImg := cli.Arg("new", `new screenshot`).Required().String()
+	maskedImg := cli.Arg("masked", `reference image whose alpha channel is transferred`).Required().String()
+	showPotentialCLIError(REMASK_USAGE, scmp.ParseArgs(cli, args[1:]))
+	conf := readOptions(flags, REMASK_USAGE)
+
+	var img, masked scmp.TaggedImage
+	err := conf.LoadImage(&img, *newImg)
+	if err == nil {
+		err = conf.LoadImage(&masked, *maskedImg)
+	}
+	if err == nil {
+		var offset image.Point
+		offset, err = img.Remask(&masked, *align)
+		if err == nil {
+			err = scmp.WritePNG(*output, img.Image)
+		}
+		if err == nil {
+			fmt.Printf("mask offset:            %d,%d\n", offset.X, offset.Y)
+			fmt.Printf("written:                %s\n", *output)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\n\033[1merror:\033[0m "+err.Error()+"\n")
+		os.Exit(101)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	scmp "github.com/GrmlForensic/screenshot-compare/v1"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+// REVIEW_USAGE for CLI command review
+const REVIEW_USAGE = `PARAMETERS
+
+  review --pending <directory>
+
+DESCRIPTION
+
+  List the failed comparisons recorded in <directory> by --pending of
+  the comparison, 'wait', 'batch' or 'dir'. Each change gives its name,
+  score, the reference image it replaces on 'approve' as well as the
+  recorded actual image and diff image (differences tinted red).
+  --pending may also be given by SCMP_PENDING or the JSON configuration.
+
+EXIT CODE
+
+  0 if no changes are pending, 100 if any change is pending
+  and 101 for any runtime error.
+`
+
+// APPROVE_USAGE for CLI command approve
+const APPROVE_USAGE = `PARAMETERS
+
+  approve --pending <directory> [<name>...]
+
+DESCRIPTION
+
+  Promote the actual images of the named changes recorded in <directory>
+  (see 'review') to reference images and remove the changes. Without
+  names, all pending changes are approved. If the previous reference image
+  has transparent areas, its alpha channel is applied to the new reference
+  image, so masks are preserved. Its dimensions must correspond then.
+  Masks are not realigned; use 'remask --align' if the screen content moved.
+  --pending may also be given by SCMP_PENDING or the JSON configuration.
+
+EXIT CODE
+
+  0 if all changes were approved and 101 for any runtime error.
+`
+
+// readPendingDir reads the pending directory of commands review and approve
+func readPendingDir(flags *scmp.ConfigFlags, usage string) string {
+	conf := readOptions(flags, usage)
+	if conf.Pending == "" {
+		showPotentialCLIError(usage, fmt.Errorf(`pending directory required; use --pending <directory>`))
+	}
+	return conf.Pending
+}
+
+// runReview implements CLI command review
+func runReview(args []string) {
+	cli := kingpin.New(args[0], REVIEW_USAGE)
+	flags := scmp.RegisterFlags(cli)
+	showPotentialCLIError(REVIEW_USAGE, scmp.ParseArgs(cli, args[1:]))
+	dir := readPendingDir(flags, REVIEW_USAGE)
+
+	changes, err := scmp.ListPending(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\n\033[1merror:\033[0m "+err.Error()+"\n")
+		os.Exit(101)
+	}
+
+	for _, change := range changes {
+		fmt.Printf("%-23s %.3f %%\n", change.Name+":", 100*change.Score)
+		fmt.Printf("  reference:            %s\n", change.Ref)
+		fmt.Printf("  actual:               %s\n", filepath.Join(change.Dir, scmp.PENDING_ACTUAL))
+		if _, err := os.Stat(filepath.Join(change.Dir, scmp.PENDING_DIFF)); err == nil {
+			fmt.Printf("  diff:                 %s\n", filepath.Join(change.Dir, scmp.PENDING_DIFF))
+		}
+	}
+	fmt.Printf("pending:                %d\n", len(changes))
+	if len(changes) > 0 {
+		os.Exit(100)
+	}
+}
+
+// runApprove implements CLI command approve
+func runApprove(args []string) {
+	cli := kingpin.New(args[0], APPROVE_USAGE)
+	flags := scmp.RegisterFlags(cli)
+	names := cli.Arg("name", `names of the changes to approve; all if omitted`).Strings()
+	showPotentialCLIError(APPROVE_USAGE, scmp.ParseArgs(cli, args[1:]))
+	dir := readPendingDir(flags, APPROVE_USAGE)
+
+	approved, err := scmp.Approve(dir, *names)
+	for _, change := range approved {
+		fmt.Printf("%-23s %s\n", change.Name+":", change.Ref)
+	}
+	fmt.Printf("approved:               %d\n", len(approved))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\n\033[1merror:\033[0m "+err.Error()+"\n")
+		os.Exit(101)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	scmp "github.com/GrmlForensic/screenshot-compare/v1"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+// STATES_USAGE for CLI command states
+const STATES_USAGE = `PARAMETERS
+
+  states [--interval <duration> | --deadline <duration>
+  | <any parameter of the comparison>]
+  <spec> [<source>]
+
+DESCRIPTION
+
+  Verify that a system goes through known screen states in order
+  (e.g. GRUB menu → boot options → bootsplash → login). Screenshots are
+  captured repeatedly from <source> and compared with the reference
+  images of the expected state until it is reached or its timeout passes.
+  A state is skipped (and the sequence fails) if a later state matches first.
+
+  <spec> is a JSON or YAML ('.yaml', '.yml') file like
+
+    source: vnc://localhost:5900
+    interval: 1s
+    states:
+      - name: grub
+        refs: [grub_menu.png]
+        timeout: 30s
+      - name: bootsplash
+        refs: [bootsplash_23sec.png, bootsplash_30sec.png]
+        mask: bootsplash_mask.png
+        threshold: 0.05
+        timeout: 2m
+
+  Every state has a list of reference images, an optional mask image
+  whose alpha channel is applied to every reference image, an optional
+  threshold (default --threshold) and an optional timeout relative to
+  the previous state (default --deadline, "0s" waits infinitely).
+  Relative filepaths are relative to the directory of <spec>.
+
+  <source> overrides the source of <spec>; it must be re-readable.
+  --interval is used if <spec> declares no interval.
+  Run screenshot-compare without command for all other parameters.
+
+EXIT CODE
+
+  0 if all states were reached in order, 100 otherwise
+  and 101 for any runtime error.
+`
+
+// runStates implements CLI command states
+func runStates(args []string) {
+	cli := kingpin.New(args[0], STATES_USAGE)
+	flags := scmp.RegisterFlags(cli)
+	spec := cli.Arg("spec", `JSON or YAML file declaring the states`).Required().String()
+	source := cli.Arg("source", `location to capture screenshots from`).String()
+	showPotentialCLIError(STATES_USAGE, scmp.ParseArgs(cli, args[1:]))
+	conf := readOptions(flags, STATES_USAGE)
+
+	var seq scmp.StateSequence
+	showPotentialCLIError(STATES_USAGE, seq.FromFile(*spec))
+	if *source != "" {
+		seq.Source = *source
+	}
+
+	result := scmp.StatesResult{}
+	if err := scmp.RunStates(conf, &seq, &result); err != nil {
+		fmt.Fprintf(os.Stderr, "\n\033[1merror:\033[0m "+err.Error()+"\n")
+		os.Exit(101)
+	}
+
+	fmt.Printf("runtime:                %s\n", result.Runtime)
+	for _, event := range result.Timeline {
+		status := "reached"
+		if !event.Reached {
+			status = "not reached"
+		}
+		fmt.Printf("%-23s %s at %s after %d attempts (%.3f %% with %s)\n", event.State+":", status, event.At, event.Attempts, 100*event.Score, event.Ref)
+	}
+	for _, state := range seq.States[len(result.Timeline):] {
+		fmt.Printf("%-23s not checked\n", state.Name+":")
+	}
+	fmt.Printf("passed:                 %t\n", result.Passed)
+	if !result.Passed {
+		fmt.Printf("failure:                %s\n", result.Failure)
+		os.Exit(100)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	scmp "github.com/GrmlForensic/screenshot-compare/v1"
+)
+
+// WAIT_USAGE for CLI command wait
+const WAIT_USAGE = `PARAMETERS
+
+  wait [--interval <duration> | --deadline <duration>
+  | <any parameter of the comparison>]
+  <base> <ref>
+
+DESCRIPTION
+
+  Wait until a live system is in a certain state: <base> is re-acquired
+  (e.g. a file overwritten by a screenshot tool or 'vnc://host:5900')
+  and compared with <ref> until the images match or the deadline passes.
+
+  --interval <duration> with default value "1s"
+    Duration between the beginnings of two attempts.
+
+  --deadline <duration> with default value "0s"
+    Maximum duration to wait for a match; "0s" waits infinitely.
+    Failing to acquire <base> or to compare it (e.g. exceeding --timeout)
+    is retried until the deadline passes.
+
+  <base> must be re-readable, i.e. it cannot be "-" (standard input).
+  Run screenshot-compare without command for all other parameters.
+
+EXIT CODE
+
+  0 if the images match within the deadline. Otherwise the exit code is
+  the difference percentage of the last attempt (at least 1), 101 for any
+  runtime error and 102 if an attempt exceeded --timeout.
+`
+
+// runWait implements CLI command wait
+func runWait(args []string) {
+	conf := readConfig(args, WAIT_USAGE)
+	result := scmp.WaitResult{}
+
+	err := scmp.WaitForMatch(conf, &result)
+	if err != nil && !result.Timeout {
+		fmt.Fprintf(os.Stderr, "\n\033[1merror:\033[0m "+err.Error()+"\n")
+		os.Exit(101)
+	}
+
+	recordPending(conf, &result.Result)
+
+	scores := make([]string, len(result.Scores))
+	for k, score := range result.Scores {
+		scores[k] = fmt.Sprintf("%.3f %%", 100*score)
+	}
+	fmt.Printf("attempts:               %d\n", result.Attempts)
+	fmt.Printf("scores of attempts:     %s\n", strings.Join(scores, ", "))
+	printResult(conf, &result.Result)
+	exitWithMatch(&result.Result)
+}
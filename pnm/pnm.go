@@ -0,0 +1,338 @@
+// Package pnm implements a decoder for the Netpbm image formats
+// PBM (P1, P4), PGM (P2, P5) and PPM (P3, P6) with 8 and 16 bits per sample.
+//
+// QEMU's `screendump` monitor command writes PPM (P6) files.
+// Importing this package registers the formats "pbm", "pgm" and "ppm" with image.Decode.
+//
+// The formats are specified at http://netpbm.sourceforge.net/doc/
+package pnm
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+)
+
+// maxPixels is the maximum number of pixels (width × height) of an image.
+// The raster is allocated before it is read, so a hostile header must not request arbitrary memory
+const maxPixels = 1 << 26
+
+// header contains the values of a PNM header
+type header struct {
+	magic  string
+	width  int
+	height int
+	maxval int
+}
+
+// FormatError reports that the input is not a valid PNM image
+type FormatError string
+
+func (e FormatError) Error() string {
+	return "pnm: invalid format: " + string(e)
+}
+
+func init() {
+	image.RegisterFormat("pbm", "P1", Decode, DecodeConfig)
+	image.RegisterFormat("pbm", "P4", Decode, DecodeConfig)
+	image.RegisterFormat("pgm", "P2", Decode, DecodeConfig)
+	image.RegisterFormat("pgm", "P5", Decode, DecodeConfig)
+	image.RegisterFormat("ppm", "P3", Decode, DecodeConfig)
+	image.RegisterFormat("ppm", "P6", Decode, DecodeConfig)
+}
+
+// DecodeConfig returns the color model and dimensions of a PNM image without decoding the entire image
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	h, err := readHeader(bufio.NewReader(r))
+	if err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{ColorModel: h.colorModel(), Width: h.width, Height: h.height}, nil
+}
+
+// Decode reads a PNM image from r and returns it as an image.Image.
+// PBM images are returned as *image.Gray. PGM images are returned as *image.Gray
+// or *image.Gray16 and PPM images as *image.RGBA or *image.RGBA64 depending on maxval.
+func Decode(r io.Reader) (image.Image, error) {
+	br := bufio.NewReader(r)
+	h, err := readHeader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	switch h.magic {
+	case "P1":
+		return decodePBMPlain(br, h)
+	case "P4":
+		return decodePBMRaw(br, h)
+	case "P2", "P5":
+		return decodeGray(br, h)
+	case "P3", "P6":
+		return decodeRGB(br, h)
+	}
+	return nil, FormatError("unknown magic number " + h.magic)
+}
+
+func (h *header) colorModel() color.Model {
+	switch {
+	case h.magic == "P1" || h.magic == "P4":
+		return color.GrayModel
+	case (h.magic == "P2" || h.magic == "P5") && h.maxval < 256:
+		return color.GrayModel
+	case h.magic == "P2" || h.magic == "P5":
+		return color.Gray16Model
+	case h.maxval < 256:
+		return color.RGBAModel
+	}
+	return color.RGBA64Model
+}
+
+// readHeader reads the magic number, dimensions and maxval
+// including the single whitespace character terminating the header
+func readHeader(br *bufio.Reader) (*header, error) {
+	magic := make([]byte, 2)
+	if _, err := io.ReadFull(br, magic); err != nil {
+		return nil, err
+	}
+	h := &header{magic: string(magic), maxval: 1}
+	if magic[0] != 'P' || magic[1] < '1' || magic[1] > '6' {
+		return nil, FormatError("unknown magic number " + h.magic)
+	}
+
+	var err error
+	if h.width, err = readInt(br); err != nil {
+		return nil, err
+	}
+	if h.height, err = readInt(br); err != nil {
+		return nil, err
+	}
+	if h.width <= 0 || h.height <= 0 {
+		return nil, FormatError(fmt.Sprintf("invalid dimensions %d×%d", h.width, h.height))
+	}
+	if h.width > maxPixels/h.height {
+		return nil, FormatError(fmt.Sprintf("dimensions %d×%d exceed %d pixels", h.width, h.height, maxPixels))
+	}
+	if h.magic != "P1" && h.magic != "P4" {
+		if h.maxval, err = readInt(br); err != nil {
+			return nil, err
+		}
+		if h.maxval <= 0 || h.maxval > 65535 {
+			return nil, FormatError(fmt.Sprintf("invalid maxval %d", h.maxval))
+		}
+	}
+
+	// exactly one whitespace character separates header and raster
+	c, err := br.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if !isWhitespace(c) {
+		return nil, FormatError("missing whitespace after header")
+	}
+	return h, nil
+}
+
+func isWhitespace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f'
+}
+
+// skipWhitespace skips whitespace and comments
+func skipWhitespace(br *bufio.Reader) error {
+	for {
+		c, err := br.ReadByte()
+		if err != nil {
+			return err
+		}
+		if c == '#' {
+			if _, err := br.ReadString('\n'); err != nil {
+				return err
+			}
+			continue
+		}
+		if !isWhitespace(c) {
+			return br.UnreadByte()
+		}
+	}
+}
+
+// readInt reads a non-negative ASCII decimal integer preceded by whitespace or comments.
+// The character terminating the integer is not consumed.
+func readInt(br *bufio.Reader) (int, error) {
+	if err := skipWhitespace(br); err != nil {
+		return 0, err
+	}
+	val, digits := 0, 0
+	for {
+		c, err := br.ReadByte()
+		if err == io.EOF && digits > 0 {
+			return val, nil
+		} else if err != nil {
+			return 0, err
+		}
+		if c < '0' || c > '9' {
+			if digits == 0 {
+				return 0, FormatError(fmt.Sprintf("unexpected character %q", c))
+			}
+			return val, br.UnreadByte()
+		}
+		val = 10*val + int(c-'0')
+		digits++
+		if val > 1<<24 {
+			return 0, FormatError("integer out of range")
+		}
+	}
+}
+
+// readSample reads one sample of a plain (ASCII) or raw (binary) raster
+func readSample(br *bufio.Reader, h *header) (int, error) {
+	if h.magic == "P2" || h.magic == "P3" {
+		v, err := readInt(br)
+		if err != nil {
+			return 0, err
+		}
+		if v > h.maxval {
+			return 0, FormatError(fmt.Sprintf("sample %d exceeds maxval %d", v, h.maxval))
+		}
+		return v, nil
+	}
+
+	hi, err := br.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	v := int(hi)
+	if h.maxval >= 256 {
+		lo, err := br.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		v = v<<8 | int(lo)
+	}
+	if v > h.maxval {
+		return 0, FormatError(fmt.Sprintf("sample %d exceeds maxval %d", v, h.maxval))
+	}
+	return v, nil
+}
+
+// scale maps a sample between 0 and maxval to a sample between 0 and max
+func scale(v, maxval, max int) int {
+	if maxval == max {
+		return v
+	}
+	return (v*max + maxval/2) / maxval
+}
+
+func decodePBMPlain(br *bufio.Reader, h *header) (image.Image, error) {
+	img := image.NewGray(image.Rect(0, 0, h.width, h.height))
+	for i := range img.Pix {
+		if err := skipWhitespace(br); err != nil {
+			return nil, err
+		}
+		c, err := br.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		switch c {
+		case '0':
+			img.Pix[i] = 0xFF
+		case '1':
+			img.Pix[i] = 0x00
+		default:
+			return nil, FormatError(fmt.Sprintf("unexpected character %q", c))
+		}
+	}
+	return img, nil
+}
+
+func decodePBMRaw(br *bufio.Reader, h *header) (image.Image, error) {
+	img := image.NewGray(image.Rect(0, 0, h.width, h.height))
+	row := make([]byte, (h.width+7)/8)
+	for y := 0; y < h.height; y++ {
+		if _, err := io.ReadFull(br, row); err != nil {
+			return nil, err
+		}
+		for x := 0; x < h.width; x++ {
+			if row[x/8]&(0x80>>uint(x%8)) == 0 {
+				img.Pix[y*img.Stride+x] = 0xFF
+			}
+		}
+	}
+	return img, nil
+}
+
+func decodeGray(br *bufio.Reader, h *header) (image.Image, error) {
+	rect := image.Rect(0, 0, h.width, h.height)
+	if h.maxval < 256 {
+		img := image.NewGray(rect)
+		for i := range img.Pix {
+			v, err := readSample(br, h)
+			if err != nil {
+				return nil, err
+			}
+			img.Pix[i] = uint8(scale(v, h.maxval, 0xFF))
+		}
+		return img, nil
+	}
+
+	img := image.NewGray16(rect)
+	for y := 0; y < h.height; y++ {
+		for x := 0; x < h.width; x++ {
+			v, err := readSample(br, h)
+			if err != nil {
+				return nil, err
+			}
+			img.SetGray16(x, y, color.Gray16{uint16(scale(v, h.maxval, 0xFFFF))})
+		}
+	}
+	return img, nil
+}
+
+func decodeRGB(br *bufio.Reader, h *header) (image.Image, error) {
+	rect := image.Rect(0, 0, h.width, h.height)
+	var rgb [3]int
+	readRGB := func() error {
+		for c := range rgb {
+			v, err := readSample(br, h)
+			if err != nil {
+				return err
+			}
+			rgb[c] = v
+		}
+		return nil
+	}
+
+	if h.maxval < 256 {
+		img := image.NewRGBA(rect)
+		for y := 0; y < h.height; y++ {
+			for x := 0; x < h.width; x++ {
+				if err := readRGB(); err != nil {
+					return nil, err
+				}
+				i := img.PixOffset(x, y)
+				img.Pix[i+0] = uint8(scale(rgb[0], h.maxval, 0xFF))
+				img.Pix[i+1] = uint8(scale(rgb[1], h.maxval, 0xFF))
+				img.Pix[i+2] = uint8(scale(rgb[2], h.maxval, 0xFF))
+				img.Pix[i+3] = 0xFF
+			}
+		}
+		return img, nil
+	}
+
+	img := image.NewRGBA64(rect)
+	for y := 0; y < h.height; y++ {
+		for x := 0; x < h.width; x++ {
+			if err := readRGB(); err != nil {
+				return nil, err
+			}
+			img.SetRGBA64(x, y, color.RGBA64{
+				uint16(scale(rgb[0], h.maxval, 0xFFFF)),
+				uint16(scale(rgb[1], h.maxval, 0xFFFF)),
+				uint16(scale(rgb[2], h.maxval, 0xFFFF)),
+				0xFFFF,
+			})
+		}
+	}
+	return img, nil
+}
@@ -0,0 +1,90 @@
+package pnm
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// checkerboard gives the expected pixels (true is white, false is black) of all 3×2 test images
+var checkerboard = [2][3]bool{{true, false, true}, {false, true, false}}
+
+func testDecode(t *testing.T, name string, data []byte, expectedFormat string, expectedModel color.Model) {
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("%s: %s", name, err)
+	}
+	if format != expectedFormat {
+		t.Fatalf("%s: expected format %s; got %s", name, expectedFormat, format)
+	}
+	if img.ColorModel() != expectedModel {
+		t.Fatalf("%s: unexpected color model %T", name, img)
+	}
+	if img.Bounds() != image.Rect(0, 0, 3, 2) {
+		t.Fatalf("%s: expected bounds 3×2; got %v", name, img.Bounds())
+	}
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 3; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			white := r == 0xFFFF && g == 0xFFFF && b == 0xFFFF && a == 0xFFFF
+			black := r == 0 && g == 0 && b == 0 && a == 0xFFFF
+			if (checkerboard[y][x] && !white) || (!checkerboard[y][x] && !black) {
+				t.Fatalf("%s: unexpected color %v at (%d, %d)", name, img.At(x, y), x, y)
+			}
+		}
+	}
+
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("%s: %s", name, err)
+	}
+	if config.Width != 3 || config.Height != 2 || config.ColorModel != expectedModel || format != expectedFormat {
+		t.Fatalf("%s: unexpected config %v", name, config)
+	}
+}
+
+func TestPlain(t *testing.T) {
+	testDecode(t, "P1", []byte("P1\n# comment\n3 2\n0 1 0\n101\n"), "pbm", color.GrayModel)
+	testDecode(t, "P2", []byte("P2\n3 2\n15\n15 0 15\n0 15 0\n"), "pgm", color.GrayModel)
+	testDecode(t, "P2 16-bit", []byte("P2 3 2 65535 65535 0 65535 0 65535 0"), "pgm", color.Gray16Model)
+	testDecode(t, "P3", []byte("P3\n3 2\n255\n255 255 255  0 0 0  255 255 255\n0 0 0  255 255 255  0 0 0\n"), "ppm", color.RGBAModel)
+}
+
+func TestRaw(t *testing.T) {
+	testDecode(t, "P4", append([]byte("P4\n3 2\n"), 0x40, 0xA0), "pbm", color.GrayModel)
+	testDecode(t, "P5", append([]byte("P5\n3 2\n255\n"), 0xFF, 0, 0xFF, 0, 0xFF, 0), "pgm", color.GrayModel)
+
+	p6 := []byte("P6\n3 2\n255\n")
+	p6x16 := []byte("P6\n3 2\n65535\n")
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 3; x++ {
+			v := byte(0)
+			if checkerboard[y][x] {
+				v = 0xFF
+			}
+			p6 = append(p6, v, v, v)
+			p6x16 = append(p6x16, v, v, v, v, v, v)
+		}
+	}
+	testDecode(t, "P6", p6, "ppm", color.RGBAModel)
+	testDecode(t, "P6 16-bit", p6x16, "ppm", color.RGBA64Model)
+}
+
+func TestInvalid(t *testing.T) {
+	invalid := map[string]string{
+		"truncated raster":            "P5\n3 2\n255\n\xFF",
+		"zero width":                  "P5\n0 2\n255\n",
+		"too many pixels":             "P6\n16777216 16777216\n255\n",
+		"maxval too large":            "P2\n3 2\n70000\n",
+		"sample too large":            "P2\n3 2\n15\n16 0 0 0 0 0\n",
+		"raw sample too large":        "P5\n3 2\n15\n\x00\x00\x10\x00\x00\x00",
+		"raw 16-bit sample too large": "P5\n1 1\n1000\n\x03\xE9",
+		"invalid bit":                 "P1\n3 2\n0 1 2 0 1 0\n",
+	}
+	for name, data := range invalid {
+		if _, err := Decode(bytes.NewReader([]byte(data))); err == nil {
+			t.Fatalf("%s: invalid image must be rejected", name)
+		}
+	}
+}
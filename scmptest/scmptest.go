@@ -0,0 +1,151 @@
+// Package scmptest provides assertions comparing images with golden (reference) images in Go tests.
+//
+//	func TestLoginScreen(t *testing.T) {
+//		screenshot := renderLoginScreen()
+//		scmptest.AssertMatches(t, screenshot, "testdata/login.png", scmptest.Threshold(0.05))
+//	}
+//
+// If an image does not match, the actual image and a diff image (see scmp.DiffImage) are written
+// to the artifact directory and reported. Running 'go test -update' rewrites the golden images
+// with the actual images instead; transparent areas of existing golden images are preserved.
+// Hence packages importing scmptest must not define their own -update flag.
+package scmptest
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	scmp "github.com/GrmlForensic/screenshot-compare/v1"
+)
+
+// update is the -update flag of 'go test'
+var update = flag.Bool("update", false, "rewrite golden images of scmptest.AssertMatches with the actual images")
+
+// options of AssertMatches
+type options struct {
+	conf      *scmp.Config
+	threshold *float64
+	artifacts string
+}
+
+// Option configures AssertMatches
+type Option func(*options)
+
+// Config sets the comparison options (color space, threshold, alpha mode, metrics, …).
+// BaseImg and RefImg are ignored. Defaults to scmp.NewConfig()
+func Config(c *scmp.Config) Option {
+	return func(o *options) {
+		conf := *c
+		o.conf = &conf
+	}
+}
+
+// Threshold sets the maximum score of matching images. Zero only accepts identical images.
+// It takes precedence over the threshold of Config regardless of the order of the options
+func Threshold(score float64) Option {
+	return func(o *options) {
+		o.threshold = &score
+	}
+}
+
+// Artifacts sets the directory actual and diff images are written to. The images are stored
+// in a subdirectory named like the test. Defaults to the environment variable SCMP_ARTIFACTS
+// or, if it is empty, to t.TempDir() (which is removed after the test)
+func Artifacts(dir string) Option {
+	return func(o *options) {
+		o.artifacts = dir
+	}
+}
+
+// AssertMatches compares actual with the image at goldenPath and marks the test as failed
+// if they do not match or the comparison fails. If the -update flag is given, the golden image
+// is rewritten with actual instead. Returns true iff the images match (or the golden image was updated)
+func AssertMatches(t testing.TB, actual image.Image, goldenPath string, opts ...Option) bool {
+	t.Helper()
+	o := options{conf: scmp.NewConfig(), artifacts: os.Getenv(`SCMP_ARTIFACTS`)}
+	o.conf.NoDimensionError = false
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.threshold != nil {
+		o.conf.Threshold = o.threshold
+	}
+
+	if *update {
+		if err := scmp.UpdateReference(goldenPath, actual); err != nil {
+			t.Errorf("updating golden image '%s': %s", goldenPath, err)
+			return false
+		}
+		t.Logf("updated golden image '%s'", goldenPath)
+		return true
+	}
+
+	c := *o.conf
+	c.BaseImg = scmp.TaggedImage{}
+	c.BaseImg.FromImage(actual, t.Name())
+	if err := c.LoadImage(&c.RefImg, goldenPath); err != nil {
+		t.Errorf("reading golden image: %s (run 'go test -update' to create it)", err)
+		return false
+	}
+
+	var r scmp.Result
+	err := scmp.Compare(&c, &r)
+	if err == nil && r.Match {
+		return true
+	}
+
+	artifacts, artifactErr := writeArtifacts(t, &c, goldenPath, o.artifacts)
+	if artifactErr != nil {
+		t.Logf("writing artifacts: %s", artifactErr)
+	}
+	if err != nil {
+		t.Errorf("comparing with golden image '%s': %s%s", goldenPath, err, artifacts)
+	} else {
+		t.Errorf("image does not match golden image '%s': score %.3f %% exceeds threshold %.3f %% (%d pixels different)%s",
+			goldenPath, 100*r.Score, 100*threshold(&c), r.PixelsDifferent, artifacts)
+	}
+	return false
+}
+
+// threshold returns the threshold the comparison used
+func threshold(c *scmp.Config) float64 {
+	if c.Threshold == nil {
+		return scmp.DEFAULT_THRESHOLD
+	}
+	return *c.Threshold
+}
+
+// writeArtifacts writes the actual image and, if the dimensions correspond, the diff image
+// to a subdirectory of dir named like the test and returns a description of their filepaths
+func writeArtifacts(t testing.TB, c *scmp.Config, goldenPath, dir string) (string, error) {
+	if dir == "" {
+		dir = t.TempDir()
+	} else {
+		dir = filepath.Join(dir, strings.NewReplacer("/", "_", `\`, "_").Replace(t.Name()))
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	name := strings.TrimSuffix(filepath.Base(goldenPath), filepath.Ext(goldenPath))
+
+	actualPath := filepath.Join(dir, name+"_actual.png")
+	if err := scmp.WritePNG(actualPath, c.BaseImg.Image); err != nil {
+		return "", err
+	}
+	desc := fmt.Sprintf("\n  actual: %s", actualPath)
+
+	diff, err := scmp.DiffImage(c)
+	if err != nil {
+		return desc, nil
+	}
+	diffPath := filepath.Join(dir, name+"_diff.png")
+	if err := scmp.WritePNG(diffPath, diff); err != nil {
+		return desc, err
+	}
+	return desc + fmt.Sprintf("\n  diff:   %s", diffPath), nil
+}
@@ -0,0 +1,108 @@
+package scmptest
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	scmp "github.com/GrmlForensic/screenshot-compare/v1"
+)
+
+// recorder captures the failures of AssertMatches instead of failing the test
+type recorder struct {
+	*testing.T
+	failures []string
+}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+// readImage decodes the image at the given filepath
+func readImage(t *testing.T, fp string) image.Image {
+	var img scmp.TaggedImage
+	if err := img.FromFilepath(fp); err != nil {
+		t.Fatal(err)
+	}
+	return img.Image
+}
+
+func TestAssertMatches(t *testing.T) {
+	if *update {
+		t.Skip("-update would overwrite the test images of the repository")
+	}
+	golden := "../tests/google_query_transparent.png"
+	rec := &recorder{T: t}
+	if !AssertMatches(rec, readImage(t, "../tests/google_query_screenshot.png"), golden) || len(rec.failures) > 0 {
+		t.Fatalf("screenshot must match its masked golden image; got %v", rec.failures)
+	}
+
+	artifacts := t.TempDir()
+	rec = &recorder{T: t}
+	if AssertMatches(rec, readImage(t, "../tests/black.png"), "../tests/white.png", Artifacts(artifacts)) {
+		t.Fatal("black must not match white")
+	}
+	if len(rec.failures) != 1 || !strings.Contains(rec.failures[0], "score 100.000 %") {
+		t.Fatalf("expected failure with score; got %v", rec.failures)
+	}
+	for _, name := range []string{"white_actual.png", "white_diff.png"} {
+		if _, err := os.Stat(filepath.Join(artifacts, t.Name(), name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conf := scmp.NewConfig()
+	conf.ColorSpace = "Y'UV"
+	rec = &recorder{T: t}
+	if !AssertMatches(rec, readImage(t, "../tests/black.png"), "../tests/blue.png", Config(conf), Threshold(0.35)) {
+		t.Fatalf("black must match blue in Y'UV with threshold 0.35; got %v", rec.failures)
+	}
+	rec = &recorder{T: t}
+	if !AssertMatches(rec, readImage(t, "../tests/black.png"), "../tests/blue.png", Threshold(0.35), Config(conf)) {
+		t.Fatalf("threshold must not depend on the order of the options; got %v", rec.failures)
+	}
+
+	rec = &recorder{T: t}
+	AssertMatches(rec, readImage(t, "../tests/black.png"), filepath.Join(artifacts, "missing.png"))
+	if len(rec.failures) != 1 || !strings.Contains(rec.failures[0], "-update") {
+		t.Fatalf("missing golden image must suggest -update; got %v", rec.failures)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	*update = true
+	defer func() { *update = false }()
+
+	dir := t.TempDir()
+	golden := filepath.Join(dir, "golden", "screen.png")
+	blue := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	draw.Draw(blue, blue.Bounds(), image.NewUniform(color.RGBA{0, 0, 0xFF, 0xFF}), image.Point{}, draw.Src)
+	if !AssertMatches(t, blue, golden) {
+		t.Fatal("missing golden image must be created")
+	}
+
+	// mask the right half of the golden image and update it with a red image
+	masked := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	draw.Draw(masked, image.Rect(0, 0, 4, 8), readImage(t, golden), image.Point{}, draw.Src)
+	if err := scmp.WritePNG(golden, masked); err != nil {
+		t.Fatal(err)
+	}
+	red := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	draw.Draw(red, red.Bounds(), image.NewUniform(color.RGBA{0xFF, 0, 0, 0xFF}), image.Point{}, draw.Src)
+	if !AssertMatches(t, red, golden) {
+		t.Fatal("golden image must be updated")
+	}
+
+	updated := readImage(t, golden)
+	if r, _, _, a := updated.At(1, 1).RGBA(); r != 0xFFFF || a != 0xFFFF {
+		t.Fatalf("golden image must be rewritten with the actual image; got %v", updated.At(1, 1))
+	}
+	if _, _, _, a := updated.At(6, 1).RGBA(); a != 0 {
+		t.Fatalf("mask of the golden image must be preserved; got alpha %d", a)
+	}
+}
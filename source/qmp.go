@@ -0,0 +1,156 @@
+package source
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	scmp "github.com/GrmlForensic/screenshot-compare/v1"
+)
+
+// qmpResponse is a message sent by a QMP server. Exactly one of the fields is set
+type qmpResponse struct {
+	Greeting json.RawMessage `json:"QMP"`
+	Return   json.RawMessage `json:"return"`
+	Error    *qmpError       `json:"error"`
+	Event    string          `json:"event"`
+}
+
+// qmpError is the error of a failed QMP command
+type qmpError struct {
+	Class string `json:"class"`
+	Desc  string `json:"desc"`
+}
+
+func (e *qmpError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Class, e.Desc)
+}
+
+// qmpConn is a connection to a QMP server in command mode
+type qmpConn struct {
+	conn    net.Conn
+	decoder *json.Decoder
+}
+
+// QMP connects to the QEMU Machine Protocol (QMP) server at the given network address
+// (e.g. "unix", "/run/qemu/qmp.sock" or "tcp", "localhost:4444"), runs the
+// `screendump` command and returns the screenshot as TaggedImage.
+// QEMU writes the screenshot to a temporary directory which is removed afterwards,
+// so QEMU must run on the same host. Only the current user may write to the
+// directory, so QEMU must run as the same user (or root). PNG is requested and QEMU versions
+// without PNG support (before 7.1) fall back to PPM.
+// A timeout of zero means no timeout.
+func QMP(network, addr string, timeout time.Duration) (*scmp.TaggedImage, error) {
+	conn, err := net.DialTimeout(network, addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if timeout > 0 {
+		conn.SetDeadline(time.Now().Add(timeout))
+	}
+
+	// Unix socket paths are absolute, giving 'qmp:///path/to/socket'
+	source := "qmp://" + addr
+	img, err := qmpScreendump(&qmpConn{conn, json.NewDecoder(bufio.NewReader(conn))}, source)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", source, err)
+	}
+	return img, nil
+}
+
+// loadQMP implements scmp.LocationLoader for URIs 'qmp:///path/to/socket' (Unix socket)
+// and 'qmp://host:port' (TCP)
+func loadQMP(i *scmp.TaggedImage, u *url.URL) error {
+	network, addr := "unix", u.Path
+	if u.Host != "" {
+		network, addr = "tcp", u.Host
+	}
+	if addr == "" {
+		return fmt.Errorf("QMP location '%s' gives neither socket path nor host", u.String())
+	}
+
+	img, err := QMP(network, addr, DefaultTimeout)
+	if err != nil {
+		return err
+	}
+	*i = *img
+	return nil
+}
+
+// qmpScreendump negotiates capabilities, runs `screendump` and reads the screenshot
+func qmpScreendump(q *qmpConn, source string) (*scmp.TaggedImage, error) {
+	greeting, err := q.receive()
+	if err != nil {
+		return nil, err
+	}
+	if greeting.Greeting == nil {
+		return nil, fmt.Errorf("expected QMP greeting")
+	}
+	if err := q.execute("qmp_capabilities", nil); err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "scmp-qmp-")
+	if err != nil {
+		return nil, err
+	}
+	// MkdirTemp creates the directory with mode 0700, so other users cannot replace the screenshot
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "screendump.png")
+	err = q.execute("screendump", map[string]string{"filename": filename, "format": "png"})
+	if e, ok := err.(*qmpError); ok && strings.Contains(e.Desc, "format") {
+		filename = filepath.Join(dir, "screendump.ppm")
+		err = q.execute("screendump", map[string]string{"filename": filename})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var img scmp.TaggedImage
+	if err := img.FromFilepath(filename); err != nil {
+		return nil, err
+	}
+	img.Source = source
+	return &img, nil
+}
+
+// execute runs a QMP command and returns its error, if any. Events are skipped
+func (q *qmpConn) execute(command string, arguments interface{}) error {
+	msg := map[string]interface{}{"execute": command}
+	if arguments != nil {
+		msg["arguments"] = arguments
+	}
+	if err := json.NewEncoder(q.conn).Encode(msg); err != nil {
+		return err
+	}
+
+	for {
+		resp, err := q.receive()
+		if err != nil {
+			return err
+		}
+		if resp.Error != nil {
+			return resp.Error
+		}
+		if resp.Return != nil {
+			return nil
+		}
+	}
+}
+
+// receive reads the next message sent by the QMP server
+func (q *qmpConn) receive() (*qmpResponse, error) {
+	var resp qmpResponse
+	if err := q.decoder.Decode(&resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
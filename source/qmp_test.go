@@ -0,0 +1,135 @@
+package source
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	scmp "github.com/GrmlForensic/screenshot-compare/v1"
+)
+
+// fakeQMPServer serves img to a single QMP client on a Unix socket.
+// If supportsPNG is false, it behaves like QEMU before 7.1 and writes PPM files only.
+// The filenames written are sent to the returned channel.
+func fakeQMPServer(t *testing.T, supportsPNG bool, img image.Image) (string, <-chan string) {
+	socket := filepath.Join(t.TempDir(), "qmp.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	written := make(chan string, 2)
+	go func() {
+		defer close(written)
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+		encoder := json.NewEncoder(conn)
+		decoder := json.NewDecoder(bufio.NewReader(conn))
+		encoder.Encode(map[string]interface{}{"QMP": map[string]interface{}{"version": map[string]interface{}{}, "capabilities": []string{}}})
+
+		for {
+			var cmd struct {
+				Execute   string            `json:"execute"`
+				Arguments map[string]string `json:"arguments"`
+			}
+			if err := decoder.Decode(&cmd); err != nil {
+				return
+			}
+			// asynchronous events may arrive at any time
+			encoder.Encode(map[string]interface{}{"event": "RTC_CHANGE", "data": map[string]int{"offset": 0}})
+
+			if cmd.Execute != "screendump" {
+				encoder.Encode(map[string]interface{}{"return": map[string]string{}})
+				continue
+			}
+			_, hasFormat := cmd.Arguments["format"]
+			if hasFormat && !supportsPNG {
+				encoder.Encode(map[string]interface{}{"error": map[string]string{"class": "GenericError", "desc": "Parameter 'format' is unexpected"}})
+				continue
+			}
+			if err := writeScreendump(cmd.Arguments["filename"], cmd.Arguments["format"] == "png", img); err != nil {
+				encoder.Encode(map[string]interface{}{"error": map[string]string{"class": "GenericError", "desc": err.Error()}})
+				continue
+			}
+			written <- cmd.Arguments["filename"]
+			encoder.Encode(map[string]interface{}{"return": map[string]string{}})
+		}
+	}()
+	return socket, written
+}
+
+// writeScreendump writes img as PNG or PPM (P6) like QEMU does
+func writeScreendump(filename string, asPNG bool, img image.Image) error {
+	fd, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+	if asPNG {
+		return png.Encode(fd, img)
+	}
+
+	bounds := img.Bounds()
+	w := bufio.NewWriter(fd)
+	fmt.Fprintf(w, "P6\n%d %d\n255\n", bounds.Dx(), bounds.Dy())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			w.Write([]byte{byte(r >> 8), byte(g >> 8), byte(b >> 8)})
+		}
+	}
+	return w.Flush()
+}
+
+func TestQMP(t *testing.T) {
+	var ref scmp.TaggedImage
+	if err := ref.FromFilepath(testImage); err != nil {
+		t.Fatal(err)
+	}
+
+	for format, supportsPNG := range map[string]bool{"png": true, "ppm": false} {
+		socket, written := fakeQMPServer(t, supportsPNG, ref.Image)
+
+		var img scmp.TaggedImage
+		if err := img.FromLocation("qmp://" + socket); err != nil {
+			t.Fatal(err)
+		}
+		if img.Format != format || img.Source != "qmp://"+socket {
+			t.Fatalf("expected %s screendump of %s; got %s", format, socket, img.String())
+		}
+
+		c := scmp.Config{ColorSpace: "RGB", BaseImg: img, RefImg: ref}
+		var r scmp.Result
+		if err := scmp.Compare(&c, &r); err != nil {
+			t.Fatal(err)
+		}
+		if r.Score != 0 {
+			t.Fatalf("%s: screendump must be equal to served image; got score %f", format, r.Score)
+		}
+
+		for filename := range written {
+			if _, err := os.Stat(filename); !os.IsNotExist(err) {
+				t.Fatalf("temporary file %s must be removed", filename)
+			}
+		}
+	}
+}
+
+func TestQMPMissingSocket(t *testing.T) {
+	if _, err := QMP("unix", filepath.Join(t.TempDir(), "missing.sock"), time.Second); err == nil {
+		t.Fatal("missing socket must be reported")
+	}
+}
@@ -0,0 +1,24 @@
+// Package source provides capture sources which acquire screenshots of running systems.
+//
+// Importing this package registers its URI schemes with scmp.RegisterScheme,
+// so the locations can be used wherever scmp.TaggedImage.FromLocation is used
+// (CLI arguments, environment variables and JSON configuration):
+//
+//	vnc://[:password@]host[:port]   framebuffer of an RFB (VNC) server
+//	qmp:///path/to/socket           QEMU screendump via a QMP Unix socket
+//	qmp://host:port                 QEMU screendump via a QMP TCP socket
+package source
+
+import (
+	"time"
+
+	scmp "github.com/GrmlForensic/screenshot-compare/v1"
+)
+
+// DefaultTimeout limits the duration of acquiring one screenshot
+var DefaultTimeout = 30 * time.Second
+
+func init() {
+	scmp.RegisterScheme("vnc", loadVNC)
+	scmp.RegisterScheme("qmp", loadQMP)
+}
@@ -0,0 +1,386 @@
+package source
+
+import (
+	"bufio"
+	"crypto/des"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	scmp "github.com/GrmlForensic/screenshot-compare/v1"
+)
+
+// RFB security types as defined in RFC 6143, section 7.2
+const (
+	rfbSecurityInvalid = 0
+	rfbSecurityNone    = 1
+	rfbSecurityVNCAuth = 2
+)
+
+// RFB server-to-client message types as defined in RFC 6143, section 7.6
+const (
+	rfbFramebufferUpdate   = 0
+	rfbSetColourMapEntries = 1
+	rfbBell                = 2
+	rfbServerCutText       = 3
+)
+
+// rfbMaxReasonLength limits the length of reason strings sent by the server
+const rfbMaxReasonLength = 64 << 10
+
+// rfbMaxPixels limits the framebuffer size announced by the server (width × height), because
+// the image is allocated before any pixel is received
+const rfbMaxPixels = 1 << 26
+
+// rfbMaxDuration limits captures without timeout, so a stalled or malicious server cannot block forever
+const rfbMaxDuration = 5 * time.Minute
+
+// VNC connects to the RFB (VNC) server at addr (e.g. 'localhost:5900'),
+// requests a full framebuffer update and returns it as TaggedImage.
+// If the server requires VNC authentication, password is used.
+// A timeout of zero means 5 minutes.
+func VNC(addr, password string, timeout time.Duration) (*scmp.TaggedImage, error) {
+	if timeout <= 0 {
+		timeout = rfbMaxDuration
+	}
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	img, err := rfbCapture(rw, password)
+	if err != nil {
+		return nil, fmt.Errorf("vnc://%s: %s", addr, err)
+	}
+
+	var i scmp.TaggedImage
+	i.FromImage(img, "vnc://"+addr)
+	i.Format = `rfb`
+	return &i, nil
+}
+
+// loadVNC implements scmp.LocationLoader for URIs 'vnc://[:password@]host[:port]'.
+// Ports below 100 are considered to be VNC display numbers (port 5900 + display).
+// If the URI contains no password, the environment variable SCMP_VNC_PASSWORD is used.
+func loadVNC(i *scmp.TaggedImage, u *url.URL) error {
+	host, port := u.Hostname(), u.Port()
+	if port == "" {
+		port = "5900"
+	} else if p, err := strconv.Atoi(port); err == nil && p < 100 {
+		port = strconv.Itoa(5900 + p)
+	}
+
+	password := os.Getenv(`SCMP_VNC_PASSWORD`)
+	if u.User != nil {
+		if p, ok := u.User.Password(); ok {
+			password = p
+		}
+	}
+
+	img, err := VNC(net.JoinHostPort(host, port), password, DefaultTimeout)
+	if err != nil {
+		return err
+	}
+	*i = *img
+	return nil
+}
+
+// rfbCapture runs the RFB handshake on rw and reads one full framebuffer
+func rfbCapture(rw *bufio.ReadWriter, password string) (*image.RGBA, error) {
+	minor, err := rfbHandshakeVersion(rw)
+	if err != nil {
+		return nil, err
+	}
+	if err := rfbHandshakeSecurity(rw, minor, password); err != nil {
+		return nil, err
+	}
+
+	// ClientInit: request shared access
+	if err := rfbSend(rw, []byte{1}); err != nil {
+		return nil, err
+	}
+
+	// ServerInit: width, height, pixel format and name
+	var serverInit struct {
+		Width, Height uint16
+		PixelFormat   [16]byte
+		NameLength    uint32
+	}
+	if err := binary.Read(rw, binary.BigEndian, &serverInit); err != nil {
+		return nil, err
+	}
+	if _, err := io.CopyN(ioutil.Discard, rw, int64(serverInit.NameLength)); err != nil {
+		return nil, err
+	}
+	width, height := int(serverInit.Width), int(serverInit.Height)
+	if width*height > rfbMaxPixels {
+		return nil, fmt.Errorf("framebuffer of %d×%d exceeds %d pixels", width, height, rfbMaxPixels)
+	}
+
+	// SetPixelFormat: 32 bits per pixel, depth 24, little-endian, true color, R<<16 | G<<8 | B
+	setPixelFormat := []byte{0, 0, 0, 0, 32, 24, 0, 1, 0, 255, 0, 255, 0, 255, 16, 8, 0, 0, 0, 0}
+	// SetEncodings: only Raw
+	setEncodings := []byte{2, 0, 0, 1, 0, 0, 0, 0}
+	if err := rfbSend(rw, append(setPixelFormat, setEncodings...)); err != nil {
+		return nil, err
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	covered := make([]bool, width*height)
+	remaining := width * height
+
+	for remaining > 0 {
+		// FramebufferUpdateRequest for the entire, non-incremental framebuffer
+		request := []byte{3, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+		binary.BigEndian.PutUint16(request[6:], uint16(width))
+		binary.BigEndian.PutUint16(request[8:], uint16(height))
+		if err := rfbSend(rw, request); err != nil {
+			return nil, err
+		}
+
+		updated := false
+		for !updated {
+			msgType, err := rw.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			switch msgType {
+			case rfbFramebufferUpdate:
+				n, err := rfbReadRects(rw, img, covered)
+				if err != nil {
+					return nil, err
+				}
+				remaining -= n
+				updated = true
+			case rfbSetColourMapEntries:
+				var hdr struct {
+					Padding    uint8
+					FirstColor uint16
+					Count      uint16
+				}
+				if err := binary.Read(rw, binary.BigEndian, &hdr); err != nil {
+					return nil, err
+				}
+				if _, err := io.CopyN(ioutil.Discard, rw, 6*int64(hdr.Count)); err != nil {
+					return nil, err
+				}
+			case rfbBell:
+			case rfbServerCutText:
+				var hdr struct {
+					Padding [3]byte
+					Length  uint32
+				}
+				if err := binary.Read(rw, binary.BigEndian, &hdr); err != nil {
+					return nil, err
+				}
+				if _, err := io.CopyN(ioutil.Discard, rw, int64(hdr.Length)); err != nil {
+					return nil, err
+				}
+			default:
+				return nil, fmt.Errorf("unknown RFB message type %d", msgType)
+			}
+		}
+	}
+
+	return img, nil
+}
+
+// rfbHandshakeVersion negotiates the protocol version and returns the minor version (3, 7 or 8)
+func rfbHandshakeVersion(rw *bufio.ReadWriter) (int, error) {
+	version := make([]byte, 12)
+	if _, err := io.ReadFull(rw, version); err != nil {
+		return 0, err
+	}
+	var major, minor int
+	if _, err := fmt.Sscanf(string(version), "RFB %03d.%03d\n", &major, &minor); err != nil || major != 3 {
+		return 0, fmt.Errorf("unsupported RFB protocol version %q", version)
+	}
+
+	switch {
+	case minor >= 8:
+		minor = 8
+	case minor == 7:
+	default:
+		minor = 3
+	}
+	return minor, rfbSend(rw, []byte(fmt.Sprintf("RFB 003.%03d\n", minor)))
+}
+
+// rfbHandshakeSecurity negotiates and runs the security type
+func rfbHandshakeSecurity(rw *bufio.ReadWriter, minor int, password string) error {
+	var securityType uint32
+	if minor == 3 {
+		if err := binary.Read(rw, binary.BigEndian, &securityType); err != nil {
+			return err
+		}
+	} else {
+		count, err := rw.ReadByte()
+		if err != nil {
+			return err
+		}
+		types := make([]byte, count)
+		if _, err := io.ReadFull(rw, types); err != nil {
+			return err
+		}
+		for _, t := range types {
+			if t == rfbSecurityNone || (t == rfbSecurityVNCAuth && securityType != rfbSecurityNone) {
+				securityType = uint32(t)
+			}
+		}
+		if count == 0 {
+			securityType = rfbSecurityInvalid
+		} else if securityType == rfbSecurityInvalid {
+			return fmt.Errorf("no supported RFB security type offered; got %v", types)
+		} else if err := rfbSend(rw, []byte{byte(securityType)}); err != nil {
+			return err
+		}
+	}
+
+	switch securityType {
+	case rfbSecurityInvalid:
+		return fmt.Errorf("RFB connection failed: %s", rfbReadReason(rw))
+	case rfbSecurityNone:
+		if minor < 8 {
+			return nil
+		}
+	case rfbSecurityVNCAuth:
+		challenge := make([]byte, 16)
+		if _, err := io.ReadFull(rw, challenge); err != nil {
+			return err
+		}
+		response, err := vncAuthResponse(challenge, password)
+		if err != nil {
+			return err
+		}
+		if err := rfbSend(rw, response); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported RFB security type %d", securityType)
+	}
+
+	// SecurityResult
+	var result uint32
+	if err := binary.Read(rw, binary.BigEndian, &result); err != nil {
+		return err
+	}
+	if result != 0 {
+		reason := "authentication failed"
+		if minor >= 8 {
+			reason = rfbReadReason(rw)
+		}
+		return fmt.Errorf("RFB security handshake failed: %s", reason)
+	}
+	return nil
+}
+
+// rfbReadRects reads the rectangles of a FramebufferUpdate message into img
+// and returns the number of newly covered pixels
+func rfbReadRects(rw *bufio.ReadWriter, img *image.RGBA, covered []bool) (int, error) {
+	var hdr struct {
+		Padding uint8
+		Count   uint16
+	}
+	if err := binary.Read(rw, binary.BigEndian, &hdr); err != nil {
+		return 0, err
+	}
+
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	newlyCovered := 0
+	for r := 0; r < int(hdr.Count); r++ {
+		var rect struct {
+			X, Y, Width, Height uint16
+			Encoding            int32
+		}
+		if err := binary.Read(rw, binary.BigEndian, &rect); err != nil {
+			return 0, err
+		}
+		if rect.Encoding != 0 {
+			return 0, fmt.Errorf("unsupported RFB encoding %d", rect.Encoding)
+		}
+
+		row := make([]byte, 4*int(rect.Width))
+		for y := int(rect.Y); y < int(rect.Y)+int(rect.Height); y++ {
+			if _, err := io.ReadFull(rw, row); err != nil {
+				return 0, err
+			}
+			for dx := 0; dx < int(rect.Width); dx++ {
+				x := int(rect.X) + dx
+				if x >= width || y >= height {
+					continue
+				}
+				// little-endian R<<16 | G<<8 | B is stored as B, G, R, X
+				o := img.PixOffset(x, y)
+				img.Pix[o+0] = row[4*dx+2]
+				img.Pix[o+1] = row[4*dx+1]
+				img.Pix[o+2] = row[4*dx+0]
+				img.Pix[o+3] = 0xFF
+				if !covered[y*width+x] {
+					covered[y*width+x] = true
+					newlyCovered++
+				}
+			}
+		}
+	}
+	return newlyCovered, nil
+}
+
+// rfbReadReason reads a reason string of a failed handshake
+func rfbReadReason(rw *bufio.ReadWriter) string {
+	var length uint32
+	if err := binary.Read(rw, binary.BigEndian, &length); err != nil {
+		return err.Error()
+	}
+	if length > rfbMaxReasonLength {
+		return fmt.Sprintf("reason of %d bytes exceeds %d bytes", length, rfbMaxReasonLength)
+	}
+	reason := make([]byte, length)
+	if _, err := io.ReadFull(rw, reason); err != nil {
+		return err.Error()
+	}
+	return string(reason)
+}
+
+// rfbSend writes data and flushes the buffer
+func rfbSend(rw *bufio.ReadWriter, data []byte) error {
+	if _, err := rw.Write(data); err != nil {
+		return err
+	}
+	return rw.Flush()
+}
+
+// vncAuthResponse encrypts the 16-byte challenge with DES using the password as key.
+// As defined by RFC 6143, section 7.2.2, only the first 8 characters of the password
+// are used and the bits of every key byte are mirrored.
+func vncAuthResponse(challenge []byte, password string) ([]byte, error) {
+	key := make([]byte, 8)
+	copy(key, password)
+	for k, b := range key {
+		var mirrored byte
+		for bit := uint(0); bit < 8; bit++ {
+			if b&(1<<bit) != 0 {
+				mirrored |= 0x80 >> bit
+			}
+		}
+		key[k] = mirrored
+	}
+
+	cipher, err := des.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	response := make([]byte, 16)
+	cipher.Encrypt(response[0:8], challenge[0:8])
+	cipher.Encrypt(response[8:16], challenge[8:16])
+	return response, nil
+}
@@ -0,0 +1,259 @@
+package source
+
+import (
+	"bytes"
+	"crypto/des"
+	"encoding/binary"
+	"image"
+	"io"
+	"math/bits"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	scmp "github.com/GrmlForensic/screenshot-compare/v1"
+)
+
+const testImage = "../tests/grmlforensic_bootsplash_23sec.png"
+
+// fakeRFBServer serves img to a single RFB client. The framebuffer is sent
+// in two FramebufferUpdate messages, so the client has to request twice.
+// If password is not empty, VNC authentication is required.
+func fakeRFBServer(t *testing.T, version, password string, img image.Image) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(10 * time.Second))
+		if err := serveRFB(conn, version, password, img); err != nil {
+			t.Log(err)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func serveRFB(conn net.Conn, version, password string, img image.Image) error {
+	discard := func(n int64) error {
+		_, err := io.CopyN(io.Discard, conn, n)
+		return err
+	}
+
+	conn.Write([]byte(version))
+	if err := discard(12); err != nil {
+		return err
+	}
+
+	securityType := byte(1)
+	if password != "" {
+		securityType = 2
+	}
+	if version == "RFB 003.003\n" {
+		binary.Write(conn, binary.BigEndian, uint32(securityType))
+	} else {
+		conn.Write([]byte{2, 2, securityType})
+		if err := discard(1); err != nil {
+			return err
+		}
+	}
+
+	if securityType == 2 {
+		challenge := []byte("0123456789abcdef")
+		conn.Write(challenge)
+		response := make([]byte, 16)
+		if _, err := io.ReadFull(conn, response); err != nil {
+			return err
+		}
+
+		key := make([]byte, 8)
+		copy(key, password)
+		for k := range key {
+			key[k] = bits.Reverse8(key[k])
+		}
+		cipher, _ := des.NewCipher(key)
+		expected := make([]byte, 16)
+		cipher.Encrypt(expected[:8], challenge[:8])
+		cipher.Encrypt(expected[8:], challenge[8:])
+		if !bytes.Equal(response, expected) {
+			binary.Write(conn, binary.BigEndian, uint32(1))
+			if version == "RFB 003.008\n" {
+				binary.Write(conn, binary.BigEndian, uint32(len("wrong password")))
+				conn.Write([]byte("wrong password"))
+			}
+			return nil
+		}
+		binary.Write(conn, binary.BigEndian, uint32(0))
+	} else if version == "RFB 003.008\n" {
+		binary.Write(conn, binary.BigEndian, uint32(0))
+	}
+
+	// ClientInit
+	if err := discard(1); err != nil {
+		return err
+	}
+
+	// ServerInit with a big-endian pixel format the client must override
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	binary.Write(conn, binary.BigEndian, uint16(width))
+	binary.Write(conn, binary.BigEndian, uint16(height))
+	conn.Write([]byte{32, 24, 1, 1, 0, 255, 0, 255, 0, 255, 0, 8, 16, 0, 0, 0})
+	binary.Write(conn, binary.BigEndian, uint32(4))
+	conn.Write([]byte("test"))
+
+	// SetPixelFormat and SetEncodings
+	if err := discard(20); err != nil {
+		return err
+	}
+	hdr := make([]byte, 4)
+	if _, err := io.ReadFull(conn, hdr); err != nil {
+		return err
+	}
+	if err := discard(4 * int64(binary.BigEndian.Uint16(hdr[2:]))); err != nil {
+		return err
+	}
+
+	halves := []image.Rectangle{
+		image.Rect(0, 0, width, height/2),
+		image.Rect(0, height/2, width, height),
+	}
+	for _, rect := range halves {
+		// FramebufferUpdateRequest
+		if err := discard(10); err != nil {
+			return err
+		}
+
+		var msg bytes.Buffer
+		msg.Write([]byte{2}) // Bell before the update
+		msg.Write([]byte{0, 0, 0, 1})
+		binary.Write(&msg, binary.BigEndian, []uint16{uint16(rect.Min.X), uint16(rect.Min.Y), uint16(rect.Dx()), uint16(rect.Dy())})
+		binary.Write(&msg, binary.BigEndian, int32(0))
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			for x := rect.Min.X; x < rect.Max.X; x++ {
+				r, g, b, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+				msg.Write([]byte{byte(b >> 8), byte(g >> 8), byte(r >> 8), 0})
+			}
+		}
+		conn.Write(msg.Bytes())
+	}
+	return nil
+}
+
+func testVNC(t *testing.T, version, serverPassword, clientPassword string) (*scmp.TaggedImage, *scmp.TaggedImage, error) {
+	var ref scmp.TaggedImage
+	if err := ref.FromFilepath(testImage); err != nil {
+		t.Fatal(err)
+	}
+	addr := fakeRFBServer(t, version, serverPassword, ref.Image)
+	img, err := VNC(addr, clientPassword, 5*time.Second)
+	return img, &ref, err
+}
+
+func TestVNC(t *testing.T) {
+	for _, version := range []string{"RFB 003.003\n", "RFB 003.007\n", "RFB 003.008\n"} {
+		for _, password := range []string{"", "secret-password"} {
+			img, ref, err := testVNC(t, version, password, password)
+			if err != nil {
+				t.Fatalf("%q with password %q: %s", version, password, err)
+			}
+			if img.Format != "rfb" || img.BitDepth != 8 {
+				t.Fatalf("unexpected format %s with bit depth %d", img.Format, img.BitDepth)
+			}
+
+			c := scmp.Config{ColorSpace: "RGB", BaseImg: *img, RefImg: *ref}
+			var r scmp.Result
+			if err := scmp.Compare(&c, &r); err != nil {
+				t.Fatal(err)
+			}
+			if r.Score != 0 {
+				t.Fatalf("%q: captured framebuffer must be equal to served image; got score %f", version, r.Score)
+			}
+		}
+	}
+}
+
+func TestVNCWrongPassword(t *testing.T) {
+	if _, _, err := testVNC(t, "RFB 003.008\n", "secret", "wrong"); err == nil {
+		t.Fatal("wrong password must be rejected")
+	}
+}
+
+func TestVNCLocation(t *testing.T) {
+	var ref scmp.TaggedImage
+	if err := ref.FromFilepath(testImage); err != nil {
+		t.Fatal(err)
+	}
+	addr := fakeRFBServer(t, "RFB 003.008\n", "secret", ref.Image)
+
+	var img scmp.TaggedImage
+	if err := img.FromLocation("vnc://:secret@" + addr); err != nil {
+		t.Fatal(err)
+	}
+	if img.Width != ref.Width || img.Height != ref.Height || img.Source != "vnc://"+addr {
+		t.Fatalf("unexpected image %s", img.String())
+	}
+}
+
+func TestVNCLongReason(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	// the server offers no security type and announces a reason of 4 GiB
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("RFB 003.008\n"))
+		io.CopyN(io.Discard, conn, 12)
+		conn.Write([]byte{0})
+		binary.Write(conn, binary.BigEndian, uint32(0xFFFFFFFF))
+	}()
+
+	_, err = VNC(ln.Addr().String(), "", 5*time.Second)
+	if err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Fatalf("reason exceeding the limit must be rejected; got %v", err)
+	}
+}
+
+func TestVNCHugeFramebuffer(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	// the server announces a framebuffer of 65535×65535 pixels
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("RFB 003.008\n"))
+		io.CopyN(io.Discard, conn, 12)
+		conn.Write([]byte{1, 1})
+		io.CopyN(io.Discard, conn, 1)
+		binary.Write(conn, binary.BigEndian, uint32(0))
+		io.CopyN(io.Discard, conn, 1)
+		binary.Write(conn, binary.BigEndian, []uint16{0xFFFF, 0xFFFF})
+		conn.Write(make([]byte, 16+4))
+	}()
+
+	_, err = VNC(ln.Addr().String(), "", 5*time.Second)
+	if err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Fatalf("framebuffer exceeding the limit must be rejected; got %v", err)
+	}
+}
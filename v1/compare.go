@@ -2,15 +2,27 @@ package v1
 
 import (
 	"fmt"
+	_ "image/gif"
 	_ "image/jpeg"
 	_ "image/png"
 	"time"
+
+	_ "github.com/GrmlForensic/screenshot-compare/pnm"
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
 )
 
 // Compare applies the two images available in Config and compares them pixel-by-pixel.
 // The result will be stored in the Result argument. If the score cannot be computed,
 // then error will be non-nil and give a reason.
+// If Config.Metrics is non-empty, all metrics are evaluated and combined into Score.
 func Compare(c *Config, r *Result) error {
+	r.BaseFormat = c.BaseImg.Format
+	r.RefFormat = c.RefImg.Format
+	r.BaseBitDepth = c.BaseImg.BitDepth
+	r.RefBitDepth = c.RefImg.BitDepth
+
 	if c.BaseImg.Width != c.RefImg.Width || c.BaseImg.Height != c.RefImg.Height {
 		if !c.NoDimensionError {
 			msg := "image dimensions do not correspond; got %d×%d (base) and %d×%d (ref)\n"
@@ -20,6 +32,8 @@ func Compare(c *Config, r *Result) error {
 			r.Runtime = time.Duration(0)
 			r.Config = c.String()
 			r.Score = 1.0
+			r.Metrics = maxMetricResults(c)
+			r.Match = false
 			return nil
 		}
 	}
@@ -81,32 +95,25 @@ func Compare(c *Config, r *Result) error {
 // compareImages corresponds to Compare, but begins comparison
 // at y=yOffset and ends at y=yOffset+yCount
 func compareImages(c *Config, r *Result, yOffset, yCount int) error {
-	roundingErrorFactor := 1.25
+	if len(c.Metrics) > 0 {
+		return compareMetrics(c, r, yOffset, yCount)
+	}
+
 	cumul := 0.0
 	r.PixelsDifferent = 0
 
 	for y := yOffset; y < yCount; y++ {
 		for x := 0; x < c.BaseImg.Width; x++ {
-			var d float64
-			r1, g1, b1, _ := toNRGBA(c.BaseImg.Image.At(c.BaseImg.MinX+x, c.BaseImg.MinY+y).RGBA())
-			r2, g2, b2, a2 := toNRGBA(c.RefImg.Image.At(c.RefImg.MinX+x, c.RefImg.MinY+y).RGBA())
+			r1, g1, b1, a1, r2, g2, b2, a2, alpha := c.samplePixels(x, y)
 			//log.Println(y, x, ":", "(1)", r1, g1, b1, a1, "(2)", r2, g2, b2, a2)
 
-			switch c.ColorSpace {
-			case "RGB":
-				d = euclideanDistance(r1, r2, g1, g2, b1, b2) / 113510.0
-			case "Y'UV":
-				yPrime1, u1, v1 := toYUV(r1, g1, b1)
-				yPrime2, u2, v2 := toYUV(r2, g2, b2)
-				d = euclideanDistance(yPrime1, yPrime2, u1, u2, v1, v2) / 113510.0
-			}
+			d := c.alphaDistance(c.pixelDistance(c.ColorSpace, r1, g1, b1, r2, g2, b2), a1, a2)
 
 			if d != 0.0 {
 				r.PixelsDifferent += 1
 			}
 
-			// NOTE only alpha channel of c.RefImg is considered
-			alpha := a2 / 65535
+			// NOTE the weight of the pixel depends on AlphaMode
 			if alpha < 0.0 || alpha > 1.0 {
 				panic(alpha) // should not occur
 			}
@@ -117,9 +124,21 @@ func compareImages(c *Config, r *Result, yOffset, yCount int) error {
 	// r.Runtime will be set from the outside
 	r.Timeout = false
 	r.Config = c.String()
-	r.Score = cumul / float64(yCount*c.BaseImg.Width) * roundingErrorFactor
-	if r.Score > 1.0 {
-		r.Score = 1.0
-	}
+	r.Score = pixelScore(cumul, (yCount-yOffset)*c.BaseImg.Width)
+	r.Match = r.Score <= c.threshold()
 	return nil
 }
+
+// pixelScore turns the alpha-weighted sum of pixel distances
+// of `count` pixels into a score between 0 and 1
+func pixelScore(cumul float64, count int) float64 {
+	roundingErrorFactor := 1.25
+	if count == 0 {
+		return 0.0
+	}
+	score := cumul / float64(count) * roundingErrorFactor
+	if score > 1.0 {
+		score = 1.0
+	}
+	return score
+}
@@ -0,0 +1,104 @@
+package v1
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+)
+
+// AlphaModes lists all supported alpha modes. With ref-mask, the alpha channel of
+// the reference image weights each pixel. With both-mask, the product of both alpha
+// channels weights each pixel. With composite-over-background, both images are flattened
+// over Config.Background. With compare-alpha-as-channel, alpha is compared like a color channel.
+var AlphaModes = []string{"ref-mask", "both-mask", "composite-over-background", "compare-alpha-as-channel"}
+
+// DEFAULT_BACKGROUND is the background color used if Config.Background is nil
+var DEFAULT_BACKGROUND = color.NRGBA{0xFF, 0xFF, 0xFF, 0xFF}
+
+// isAlphaMode tells whether the given string is a supported alpha mode
+func isAlphaMode(s string) bool {
+	if s == "" {
+		return true
+	}
+	for _, mode := range AlphaModes {
+		if s == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// background returns Background or DEFAULT_BACKGROUND if Background is nil
+func (c *Config) background() color.Color {
+	if c.Background == nil {
+		return DEFAULT_BACKGROUND
+	}
+	return c.Background
+}
+
+// samplePixels returns the un-alpha-scaled colors of the base image and the
+// reference image at (x, y) relative to MinX and MinY. The last return value
+// is the weight of this pixel between 0 and 1 as defined by AlphaMode.
+// If Linear is set, the colors are converted to linear light.
+func (c *Config) samplePixels(x, y int) (r1, g1, b1, a1, r2, g2, b2, a2, weight float64) {
+	base := c.BaseImg.Image.At(c.BaseImg.MinX+x, c.BaseImg.MinY+y)
+	ref := c.RefImg.Image.At(c.RefImg.MinX+x, c.RefImg.MinY+y)
+
+	if c.AlphaMode == "composite-over-background" {
+		bg := c.background()
+		r1, g1, b1, a1 = toNRGBA(composite(base, bg).RGBA())
+		r2, g2, b2, a2 = toNRGBA(composite(ref, bg).RGBA())
+		if c.Linear {
+			r1, g1, b1 = toLinear(r1), toLinear(g1), toLinear(b1)
+			r2, g2, b2 = toLinear(r2), toLinear(g2), toLinear(b2)
+		}
+		return r1, g1, b1, a1, r2, g2, b2, a2, 1.0
+	}
+
+	r1, g1, b1, a1 = toNRGBA(base.RGBA())
+	r2, g2, b2, a2 = toNRGBA(ref.RGBA())
+
+	if c.Linear {
+		r1, g1, b1 = toLinear(r1), toLinear(g1), toLinear(b1)
+		r2, g2, b2 = toLinear(r2), toLinear(g2), toLinear(b2)
+	}
+
+	switch c.AlphaMode {
+	case "both-mask":
+		weight = (a1 / 65535) * (a2 / 65535)
+	case "compare-alpha-as-channel":
+		weight = 1.0
+	default:
+		weight = a2 / 65535
+	}
+	return r1, g1, b1, a1, r2, g2, b2, a2, weight
+}
+
+// alphaDistance combines the color distance d with the difference of the alpha values
+// a1 and a2 if AlphaMode is compare-alpha-as-channel. Otherwise d is returned.
+func (c *Config) alphaDistance(d, a1, a2 float64) float64 {
+	if c.AlphaMode != "compare-alpha-as-channel" {
+		return d
+	}
+	da := math.Abs(a1-a2) / 65535
+	return math.Sqrt((d*d + da*da) / 2)
+}
+
+// composite flattens the color fg over the opaque color bg
+func composite(fg, bg color.Color) color.Color {
+	r, g, b, a := fg.RGBA()
+	br, bgg, bb, _ := bg.RGBA()
+	t := 0xFFFF - a
+	return color.RGBA64{
+		uint16(r + br*t/0xFFFF),
+		uint16(g + bgg*t/0xFFFF),
+		uint16(b + bb*t/0xFFFF),
+		0xFFFF,
+	}
+}
+
+// colorToHex returns the hexadecimal representation '#rrggbbaa' of a color
+func colorToHex(col color.Color) string {
+	n := color.NRGBAModel.Convert(col).(color.NRGBA)
+	return fmt.Sprintf(`#%02x%02x%02x%02x`, n.R, n.G, n.B, n.A)
+}
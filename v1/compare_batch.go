@@ -0,0 +1,92 @@
+package v1
+
+import (
+	"fmt"
+	"time"
+)
+
+// BatchComparisonResult is the result of one comparison of RunBatch
+type BatchComparisonResult struct {
+	// Result is the result of the comparison
+	Result
+	// Name identifies the comparison of the Manifest
+	Name string
+	// Err is the error which occurred while loading the images or comparing them. Nil means none
+	Err error
+}
+
+// BatchResult is the result of RunBatch
+type BatchResult struct {
+	// Comparisons gives the results in the order of Manifest.Comparisons
+	Comparisons []BatchComparisonResult
+	// Failed gives the number of comparisons which did not match or failed with an error
+	Failed int
+	// Runtime gives the duration of all comparisons
+	Runtime time.Duration
+}
+
+// Passed returns true iff all comparisons matched
+func (r *BatchResult) Passed() bool {
+	return r.Failed == 0
+}
+
+// RunBatch runs all comparisons of the manifest in parallel. Images are loaded by the
+// comparisons themselves, so at most as many images as CPUs are held in memory at once.
+// If Config.Frames of a comparison is set, CompareFrames is used instead of Compare.
+// Config.PreWait of a comparison is waited for before that comparison, i.e. concurrently
+// with other comparisons, since each comparison may declare its own options.
+// If Config.Pending of a comparison is set, comparisons which do not match are recorded there by name.
+//
+// Comparisons which do not match or fail are not an error; they are counted in BatchResult.Failed.
+func RunBatch(m *Manifest, r *BatchResult) error {
+	if len(m.Comparisons) == 0 {
+		return fmt.Errorf(`manifest declares no comparisons`)
+	}
+
+	beforeTime := time.Now()
+	results := make([]BatchComparisonResult, len(m.Comparisons))
+	parallelize(len(m.Comparisons), func(k int) {
+		results[k].Name = m.Comparisons[k].Name
+		results[k].Err = runBatchComparison(&m.Comparisons[k], &results[k].Result)
+	})
+
+	r.Comparisons = results
+	r.Failed = 0
+	for _, result := range results {
+		if result.Err != nil || !result.Match {
+			r.Failed++
+		}
+	}
+	r.Runtime = time.Since(beforeTime)
+	return nil
+}
+
+// runBatchComparison loads the images of a comparison and compares them.
+// A failed comparison is recorded in Config.Pending (see RecordPending)
+func runBatchComparison(bc *BatchComparison, r *Result) error {
+	c := bc.Config
+	if err := c.LoadImage(&c.BaseImg, bc.BaseImg); err != nil {
+		return err
+	}
+	if err := c.LoadImage(&c.RefImg, bc.RefImg); err != nil {
+		return err
+	}
+	if bc.Mask != "" {
+		var mask TaggedImage
+		if err := c.LoadImage(&mask, bc.Mask); err != nil {
+			return err
+		}
+		if err := c.RefImg.ApplyMask(&mask); err != nil {
+			return err
+		}
+	}
+
+	compare := Compare
+	if c.Frames {
+		compare = CompareFrames
+	}
+	if err := compare(&c, r); err != nil {
+		return err
+	}
+	return RecordPending(&c, r, bc.Name)
+}
@@ -0,0 +1,87 @@
+package v1
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunBatch(t *testing.T) {
+	dir := t.TempDir()
+	abs := func(name string) string {
+		fp, err := filepath.Abs(FILES[name])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fp
+	}
+
+	// a fully transparent mask ignores all differences
+	f, err := os.Create(filepath.Join(dir, "mask.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	png.Encode(f, image.NewNRGBA(image.Rect(0, 0, 1, 1)))
+	f.Close()
+
+	manifest := fmt.Sprintf(`{
+  "defaults": {"colors": "Y'UV", "threshold": 0.05},
+  "comparisons": [
+    {"name": "same", "baseimg": %q, "refimg": %q},
+    {"name": "different", "baseimg": %q, "refimg": %q, "colors": "RGB", "diffpixel": 1},
+    {"name": "masked", "baseimg": %q, "refimg": %q, "mask": "mask.png"},
+    {"name": "missing", "baseimg": "missing.png", "refimg": %q}
+  ]
+}`, abs("black"), abs("black"), abs("black"), abs("white"), abs("black"), abs("white"), abs("white"))
+	fp := filepath.Join(dir, "manifest.json")
+	if err := os.WriteFile(fp, []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var m Manifest
+	defaults := defaultConfig()
+	defaults.AdmissibleDiffPixel = 7
+	if err := m.FromFile(fp, &defaults); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Comparisons) != 4 {
+		t.Fatalf("expected 4 comparisons; got %d", len(m.Comparisons))
+	}
+	same, different := m.Comparisons[0].Config, m.Comparisons[1].Config
+	if same.ColorSpace != "Y'UV" || same.Threshold == nil || *same.Threshold != 0.05 || same.AdmissibleDiffPixel != 7 {
+		t.Fatalf("defaults must be applied; got %s", same.String())
+	}
+	if different.ColorSpace != "RGB" || different.AdmissibleDiffPixel != 1 {
+		t.Fatalf("options of comparison must override defaults; got %s", different.String())
+	}
+	if m.Comparisons[2].Mask != filepath.Join(dir, "mask.png") || m.Comparisons[3].BaseImg != filepath.Join(dir, "missing.png") {
+		t.Fatal("relative filepaths must be relative to the manifest")
+	}
+
+	var r BatchResult
+	if err := RunBatch(&m, &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Passed() || r.Failed != 2 || len(r.Comparisons) != 4 {
+		t.Fatalf("expected 2 of 4 failed comparisons; got %d", r.Failed)
+	}
+	expected := []bool{true, false, true, false}
+	for k, c := range r.Comparisons {
+		if c.Name != m.Comparisons[k].Name || c.Match != expected[k] {
+			t.Fatalf("comparison '%s': expected match %t; got %t (%v)", c.Name, expected[k], c.Match, c.Err)
+		}
+	}
+	if r.Comparisons[3].Err == nil {
+		t.Fatal("missing image must be reported as error")
+	}
+
+	if err := os.WriteFile(fp, []byte(`{"comparisons": [{"name": "a", "baseimg": "a.png"}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.FromFile(fp, &defaults); err == nil {
+		t.Fatal("comparison without refimg must be rejected")
+	}
+}
@@ -0,0 +1,91 @@
+package v1
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Classification is the result of comparing the base image with one reference image in Classify
+type Classification struct {
+	// Result is the result of the comparison with this reference image
+	Result
+	// Index gives the position of the reference image in the slice given to Classify
+	Index int
+	// Ref gives the source of the reference image
+	Ref string
+}
+
+// ClassifyResult is the result of Classify
+type ClassifyResult struct {
+	// Ranking gives the comparison with every reference image in ascending order of scores.
+	// Reference images with equal scores keep their order
+	Ranking []Classification
+	// Match is true iff the best-ranked reference image matches the base image.
+	// Otherwise the base image is classified as "no match"
+	Match bool
+	// Runtime gives the duration of all comparisons plus waiting times
+	Runtime time.Duration
+	// True, if any comparison did not finish within the timeframe given by Timeout
+	Timeout bool
+}
+
+// Best returns the best-ranked classification if it matches and nil otherwise ("no match")
+func (r *ClassifyResult) Best() *Classification {
+	if !r.Match || len(r.Ranking) == 0 {
+		return nil
+	}
+	return &r.Ranking[0]
+}
+
+// Classify compares BaseImg with every given reference image concurrently and ranks
+// the reference images by their score. Config.RefImg is ignored. Config.Timeout applies
+// to every single comparison and Config.PreWait is waited for once before all comparisons.
+// If Config.Frames is set, CompareFrames is used instead of Compare.
+//
+// The base image not matching any reference image is not an error; ClassifyResult.Match is false then.
+func Classify(c *Config, refs []TaggedImage, r *ClassifyResult) error {
+	if len(refs) == 0 {
+		return fmt.Errorf(`at least one reference image required`)
+	}
+
+	beforeTime := time.Now()
+	if c.PreWait > time.Duration(0) {
+		time.Sleep(c.PreWait)
+	}
+
+	compare := Compare
+	if c.Frames {
+		compare = CompareFrames
+	}
+
+	ranking := make([]Classification, len(refs))
+	errs := make([]error, len(refs))
+	parallelize(len(refs), func(k int) {
+		rc := *c
+		rc.RefImg = refs[k]
+		rc.PreWait = 0
+		ranking[k].Index = k
+		ranking[k].Ref = refs[k].Source
+		errs[k] = compare(&rc, &ranking[k].Result)
+	})
+
+	r.Timeout = false
+	for k, err := range errs {
+		if ranking[k].Timeout {
+			r.Timeout = true
+		}
+		if err != nil {
+			r.Runtime = time.Since(beforeTime)
+			return fmt.Errorf(`reference image '%s': %s`, refs[k].Source, err)
+		}
+	}
+
+	sort.SliceStable(ranking, func(i, j int) bool {
+		return ranking[i].Score < ranking[j].Score
+	})
+	r.Ranking = ranking
+	r.Match = ranking[0].Match
+	r.Runtime = time.Since(beforeTime)
+	return nil
+}
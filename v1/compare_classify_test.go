@@ -0,0 +1,52 @@
+package v1
+
+import "testing"
+
+func TestClassify(t *testing.T) {
+	c := defaultConfig()
+	c.NoDimensionError = true
+	if err := c.LoadImage(&c.BaseImg, FILES["grmlf_bs_23"]); err != nil {
+		t.Fatal(err)
+	}
+	names := []string{"black", "grmlf_bs_graphical", "grmlf_bs_23", "grmlf_bs_30"}
+	refs := make([]TaggedImage, len(names))
+	for k, name := range names {
+		if err := c.LoadImage(&refs[k], FILES[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var r ClassifyResult
+	if err := Classify(&c, refs, &r); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Ranking) != len(refs) {
+		t.Fatalf("expected %d classifications; got %d", len(refs), len(r.Ranking))
+	}
+	if !r.Match || r.Best() == nil || r.Best().Index != 2 || r.Best().Ref != FILES["grmlf_bs_23"] || r.Best().Score != 0 {
+		t.Fatalf("identical image must be ranked first; got %v", r.Ranking[0])
+	}
+	for k := 1; k < len(r.Ranking); k++ {
+		if r.Ranking[k-1].Score > r.Ranking[k].Score {
+			t.Fatalf("ranking must be in ascending order of scores; got %v", r.Ranking)
+		}
+	}
+	if last := r.Ranking[len(r.Ranking)-1]; last.Index != 0 || last.Score != 1 {
+		t.Fatalf("image with different dimensions must be ranked last; got %v", last)
+	}
+
+	// no reference image matches
+	if err := c.LoadImage(&c.BaseImg, FILES["white"]); err != nil {
+		t.Fatal(err)
+	}
+	if err := Classify(&c, refs[:2], &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Match || r.Best() != nil {
+		t.Fatalf("base image must be classified as no match; got %v", r.Ranking[0])
+	}
+
+	if err := Classify(&c, nil, &r); err == nil {
+		t.Fatal("classification without reference images must fail")
+	}
+}
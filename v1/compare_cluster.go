@@ -0,0 +1,189 @@
+package v1
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// ClusterMethods lists the methods supported by ClusterImages
+var ClusterMethods = []string{"threshold", "hierarchical"}
+
+// isClusterMethod returns true if the given method is one of ClusterMethods
+func isClusterMethod(method string) bool {
+	for _, m := range ClusterMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// Cluster is a set of images showing the same visual state
+type Cluster struct {
+	// Representative gives the location of the medoid, i.e. the member with
+	// the smallest sum of distances to all other members
+	Representative string
+	// Members lists the locations of all images of the cluster in input order
+	Members []string
+}
+
+// ClusterResult is the result of ClusterImages
+type ClusterResult struct {
+	// Clusters lists the clusters in order of the first occurrence of a member
+	Clusters []Cluster
+	// Skipped lists the locations which are not images
+	Skipped []SkippedImage
+	// Runtime gives the duration of all comparisons and the clustering
+	Runtime time.Duration
+}
+
+// ClusterOf returns the index in Clusters of the cluster containing the image at the given location
+// or -1 if no cluster contains it
+func (r *ClusterResult) ClusterOf(location string) int {
+	for k, cluster := range r.Clusters {
+		for _, member := range cluster.Members {
+			if member == location {
+				return k
+			}
+		}
+	}
+	return -1
+}
+
+// ClusterImages groups the images at the given locations into clusters of distinct visual states
+// using the options of the given Config (its images are ignored). The distance of two images is
+// the maximum score of comparing them in both directions; images with different dimensions
+// have distance 1. Locations which cannot be read as image are skipped. Supported methods:
+//
+//	"threshold"     every image joins the first cluster whose first member is within
+//	                Config.Threshold, otherwise it starts a new cluster
+//	"hierarchical"  agglomerative clustering with complete linkage, i.e. clusters are merged
+//	                as long as all pairs of members are within Config.Threshold
+//
+// Every pair of images is compared (in parallel), so the runtime grows quadratically.
+func ClusterImages(c *Config, locations []string, method string, r *ClusterResult) error {
+	if !isClusterMethod(method) {
+		return fmt.Errorf("unknown cluster method '%s'; supported methods: %s", method, strings.Join(ClusterMethods, ", "))
+	}
+
+	beforeTime := time.Now()
+	var m MatrixResult
+	if err := ComputeMatrix(c, locations, &m); err != nil {
+		return err
+	}
+	n := len(m.Images)
+	dist := make([][]float64, n)
+	for i := range dist {
+		dist[i] = make([]float64, n)
+		for j := range dist[i] {
+			for _, cell := range []MatrixCell{m.Cells[i][j], m.Cells[j][i]} {
+				if cell.Err != nil {
+					return fmt.Errorf("comparing '%s' and '%s': %s", m.Images[i], m.Images[j], cell.Err)
+				}
+				d := cell.Score
+				if cell.Mismatch {
+					d = 1.0
+				}
+				dist[i][j] = math.Max(dist[i][j], d)
+			}
+		}
+	}
+
+	var groups [][]int
+	switch method {
+	case "threshold":
+		groups = clusterByThreshold(dist, c.threshold())
+	case "hierarchical":
+		groups = clusterHierarchically(dist, c.threshold())
+	}
+
+	r.Clusters = make([]Cluster, len(groups))
+	for k, group := range groups {
+		medoid, medoidSum := group[0], math.Inf(1)
+		for _, i := range group {
+			sum := 0.0
+			for _, j := range group {
+				sum += dist[i][j]
+			}
+			if sum < medoidSum {
+				medoid, medoidSum = i, sum
+			}
+			r.Clusters[k].Members = append(r.Clusters[k].Members, m.Images[i])
+		}
+		r.Clusters[k].Representative = m.Images[medoid]
+	}
+	r.Skipped = m.Skipped
+	r.Runtime = time.Since(beforeTime)
+	return nil
+}
+
+// clusterByThreshold assigns every image to the first cluster whose first member is within threshold
+func clusterByThreshold(dist [][]float64, threshold float64) [][]int {
+	var groups [][]int
+	for i := range dist {
+		assigned := false
+		for k, group := range groups {
+			if dist[i][group[0]] <= threshold {
+				groups[k] = append(group, i)
+				assigned = true
+				break
+			}
+		}
+		if !assigned {
+			groups = append(groups, []int{i})
+		}
+	}
+	return groups
+}
+
+// clusterHierarchically merges the two clusters with the smallest complete-linkage distance
+// (maximum distance of their members) as long as it does not exceed threshold.
+// Groups and their members are sorted by their first image
+func clusterHierarchically(dist [][]float64, threshold float64) [][]int {
+	groups := make([][]int, len(dist))
+	for i := range dist {
+		groups[i] = []int{i}
+	}
+
+	linkage := func(a, b []int) float64 {
+		d := 0.0
+		for _, i := range a {
+			for _, j := range b {
+				d = math.Max(d, dist[i][j])
+			}
+		}
+		return d
+	}
+
+	for len(groups) > 1 {
+		bestA, bestB, best := -1, -1, math.Inf(1)
+		for a := range groups {
+			for b := a + 1; b < len(groups); b++ {
+				if d := linkage(groups[a], groups[b]); d < best {
+					bestA, bestB, best = a, b, d
+				}
+			}
+		}
+		if best > threshold {
+			break
+		}
+		groups[bestA] = mergeSorted(groups[bestA], groups[bestB])
+		groups = append(groups[:bestB], groups[bestB+1:]...)
+	}
+	return groups
+}
+
+// mergeSorted merges two sorted slices of indices
+func mergeSorted(a, b []int) []int {
+	merged := make([]int, 0, len(a)+len(b))
+	for len(a) > 0 && len(b) > 0 {
+		if a[0] < b[0] {
+			merged, a = append(merged, a[0]), a[1:]
+		} else {
+			merged, b = append(merged, b[0]), b[1:]
+		}
+	}
+	return append(append(merged, a...), b...)
+}
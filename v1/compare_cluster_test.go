@@ -0,0 +1,95 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestClusterImages(t *testing.T) {
+	locations := []string{
+		FILES["grmlf_bs_23"], FILES["black"], FILES["grmlf_bo_back"], FILES["grmlf_bs_30"],
+		FILES["grmlf_bo_debug"], FILES["white"], filepath.Join("../tests", "results_table.adoc"),
+	}
+	c := defaultConfig()
+	threshold := 0.02
+	c.Threshold = &threshold
+
+	expected := [][]string{
+		{FILES["grmlf_bs_23"], FILES["grmlf_bs_30"]},
+		{FILES["black"]},
+		{FILES["grmlf_bo_back"]},
+		{FILES["grmlf_bo_debug"]},
+		{FILES["white"]},
+	}
+	for _, method := range ClusterMethods {
+		var r ClusterResult
+		if err := ClusterImages(&c, locations, method, &r); err != nil {
+			t.Fatal(err)
+		}
+		if len(r.Skipped) != 1 || len(r.Clusters) != len(expected) {
+			t.Fatalf("%s: expected %d clusters; got %v", method, len(expected), r.Clusters)
+		}
+		for k, cluster := range r.Clusters {
+			if !reflect.DeepEqual(cluster.Members, expected[k]) || cluster.Representative != cluster.Members[0] {
+				t.Fatalf("%s: cluster %d: expected %v; got %v", method, k, expected[k], cluster)
+			}
+		}
+		if r.ClusterOf(FILES["grmlf_bs_30"]) != 0 || r.ClusterOf(locations[6]) != -1 {
+			t.Fatalf("%s: unexpected assignment of images", method)
+		}
+	}
+
+	if err := ClusterImages(&c, locations, "k-means", &ClusterResult{}); err == nil {
+		t.Fatal("unknown method must be rejected")
+	}
+}
+
+func TestHierarchicalClustering(t *testing.T) {
+	// 0 and 1 as well as 1 and 2 are close, but 0 and 2 are not: complete linkage
+	// must not chain them, whereas the threshold method assigns 2 to a new cluster, too
+	dist := [][]float64{
+		{0, 0.05, 0.15, 1},
+		{0.05, 0, 0.04, 1},
+		{0.15, 0.04, 0, 1},
+		{1, 1, 1, 0},
+	}
+	if groups := clusterHierarchically(dist, 0.1); !reflect.DeepEqual(groups, [][]int{{0}, {1, 2}, {3}}) {
+		t.Fatalf("unexpected hierarchical clusters %v", groups)
+	}
+	if groups := clusterByThreshold(dist, 0.1); !reflect.DeepEqual(groups, [][]int{{0, 1}, {2}, {3}}) {
+		t.Fatalf("unexpected threshold clusters %v", groups)
+	}
+}
+
+func TestClusterReport(t *testing.T) {
+	r := ClusterResult{Clusters: []Cluster{
+		{Representative: "b.png", Members: []string{"a.png", "b.png", "c.png"}},
+		{Representative: "d.png", Members: []string{"d.png"}},
+	}}
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf, "csv"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "c.png,1,b.png\nd.png,2,d.png\n") {
+		t.Fatalf("unexpected CSV report\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := r.Write(&buf, "json"); err != nil {
+		t.Fatal(err)
+	}
+	var report struct {
+		Images map[string]int `json:"images"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Images["a.png"] != 1 || report.Images["d.png"] != 2 {
+		t.Fatalf("unexpected JSON report\n%s", buf.String())
+	}
+}
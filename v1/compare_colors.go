@@ -11,6 +11,24 @@ const WG = float64(0.587)
 // WB as defined by standard BT.601 by CCIR
 const WB = float64(0.114)
 
+// WR709 as defined by standard BT.709 by ITU-R
+const WR709 = float64(0.2126)
+
+// WG709 as defined by standard BT.709 by ITU-R
+const WG709 = float64(0.7152)
+
+// WB709 as defined by standard BT.709 by ITU-R
+const WB709 = float64(0.0722)
+
+// MAX_RGB_DISTANCE normalizes euclidean distances of three channels to [0, 1].
+// image.Color.RGBA() always returns 16 bits per channel (8-bit values v are scaled
+// to v·0x101), so the distance of black and white is √3 · 65535 ≈ 113510
+// independent of the bit depth of the source image.
+const MAX_RGB_DISTANCE = float64(113510.0)
+
+// ColorSpaces lists all supported color spaces
+var ColorSpaces = []string{"RGB", "Y'UV", "Gray", "R", "G", "B", "Y"}
+
 // toNRGBA converts a RGBA color to un-alpha-scaled NRGBA
 // based on https://golang.org/src/image/color/color.go?s=4600:4767
 func toNRGBA(r, g, b, a uint32) (float64, float64, float64, float64) {
@@ -22,6 +40,16 @@ func toNRGBA(r, g, b, a uint32) (float64, float64, float64, float64) {
 	return float64(r*0xFFFF) / d, float64(g*0xFFFF) / d, float64(b*0xFFFF) / d, d
 }
 
+// toLinear converts a gamma-encoded sRGB channel value to linear light
+// based on https://en.wikipedia.org/wiki/SRGB#Transformation
+func toLinear(v float64) float64 {
+	v /= 65535
+	if v <= 0.04045 {
+		return v / 12.92 * 65535
+	}
+	return math.Pow((v+0.055)/1.055, 2.4) * 65535
+}
+
 // toYUV converts a RGB color to the Y'UV color space
 func toYUV(r, g, b float64) (float64, float64, float64) {
 	// https://en.wikipedia.org/wiki/YUV#SDTV_with_BT.601
@@ -29,6 +57,55 @@ func toYUV(r, g, b float64) (float64, float64, float64) {
 	return yPrime, 0.492 * (b - yPrime), 0.877 * (r - yPrime)
 }
 
+// toLuma converts a RGB color to its luma value using the weights of the given standard
+func toLuma(r, g, b float64, standard string) float64 {
+	if standard == "BT.709" {
+		return WR709*r + WG709*g + WB709*b
+	}
+	return WR*r + WG*g + WB*b
+}
+
+// pixelDistance returns the difference of two un-alpha-scaled colors
+// in the given color space. The result is between 0 and 1 (inclusively).
+func (c *Config) pixelDistance(colorSpace string, r1, g1, b1, r2, g2, b2 float64) float64 {
+	switch colorSpace {
+	case "RGB":
+		return euclideanDistance(r1, r2, g1, g2, b1, b2) / MAX_RGB_DISTANCE
+	case "Y'UV":
+		yPrime1, u1, v1 := toYUV(r1, g1, b1)
+		yPrime2, u2, v2 := toYUV(r2, g2, b2)
+		return euclideanDistance(yPrime1, yPrime2, u1, u2, v1, v2) / MAX_RGB_DISTANCE
+	case "Gray":
+		return math.Abs(toLuma(r1, g1, b1, c.Luma)-toLuma(r2, g2, b2, c.Luma)) / 65535.0
+	case "R":
+		return math.Abs(r1-r2) / 65535.0
+	case "G":
+		return math.Abs(g1-g2) / 65535.0
+	case "B":
+		return math.Abs(b1-b2) / 65535.0
+	case "Y":
+		yPrime1, _, _ := toYUV(r1, g1, b1)
+		yPrime2, _, _ := toYUV(r2, g2, b2)
+		return math.Abs(yPrime1-yPrime2) / 65535.0
+	}
+	return 0.0
+}
+
+// isColorSpace tells whether the given string is a supported color space
+func isColorSpace(s string) bool {
+	for _, colorSpace := range ColorSpaces {
+		if s == colorSpace {
+			return true
+		}
+	}
+	return false
+}
+
+// isLumaStandard tells whether the given string is a supported standard for luma weights
+func isLumaStandard(s string) bool {
+	return s == "" || s == "BT.601" || s == "BT.709"
+}
+
 func euclideanDistance(a, x, b, y, c, z float64) float64 {
 	return math.Sqrt(math.Pow(a-x, 2) + math.Pow(b-y, 2) + math.Pow(c-z, 2))
 }
@@ -0,0 +1,117 @@
+package v1
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// DirResult is the result of CompareDirs
+type DirResult struct {
+	// BatchResult gives the comparisons of all images present in both directories.
+	// Comparisons are named by the relative filepath of the images (with slashes)
+	BatchResult
+	// Missing lists the relative filepaths of images in the expected, but not in the actual directory
+	Missing []string
+	// Extra lists the relative filepaths of images in the actual, but not in the expected directory
+	Extra []string
+}
+
+// Passed returns true iff all images match and no image is missing or extra
+func (r *DirResult) Passed() bool {
+	return r.Failed == 0 && len(r.Missing) == 0 && len(r.Extra) == 0
+}
+
+// CompareDirs pairs the images of two directory trees by their relative filepath and compares
+// every pair in parallel using the options of the given Config (its images are ignored).
+// Images of actualDir are the base images and images of expectedDir the reference images.
+// Files with other extensions than ImageExtensions are ignored.
+// Config.PreWait is waited for once before all comparisons.
+//
+// Pairs which do not match and missing or extra images are not an error; see DirResult.Passed.
+func CompareDirs(c *Config, actualDir, expectedDir string, r *DirResult) error {
+	beforeTime := time.Now()
+	actual, err := listImages(actualDir)
+	if err != nil {
+		return err
+	}
+	expected, err := listImages(expectedDir)
+	if err != nil {
+		return err
+	}
+	inActual := make(map[string]bool, len(actual))
+	for _, rel := range actual {
+		inActual[rel] = true
+	}
+	inExpected := make(map[string]bool, len(expected))
+	for _, rel := range expected {
+		inExpected[rel] = true
+	}
+
+	var m Manifest
+	options := *c
+	options.BaseImg, options.RefImg = TaggedImage{}, TaggedImage{}
+	options.PreWait = 0
+	r.Missing, r.Extra = nil, nil
+	for _, rel := range expected {
+		if !inActual[rel] {
+			r.Missing = append(r.Missing, rel)
+			continue
+		}
+		m.Comparisons = append(m.Comparisons, BatchComparison{
+			Name:    rel,
+			Config:  options,
+			BaseImg: filepath.Join(actualDir, filepath.FromSlash(rel)),
+			RefImg:  filepath.Join(expectedDir, filepath.FromSlash(rel)),
+		})
+	}
+	for _, rel := range actual {
+		if !inExpected[rel] {
+			r.Extra = append(r.Extra, rel)
+		}
+	}
+
+	r.BatchResult = BatchResult{}
+	if len(m.Comparisons) > 0 {
+		if c.PreWait > time.Duration(0) {
+			time.Sleep(c.PreWait)
+		}
+		if err := RunBatch(&m, &r.BatchResult); err != nil {
+			return err
+		}
+	}
+	r.Runtime = time.Since(beforeTime)
+	return nil
+}
+
+// listImages returns the sorted relative filepaths (with slashes) of all image files in a directory tree
+func listImages(dir string) ([]string, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf(`'%s' is not a directory`, dir)
+	}
+
+	var images []string
+	err = filepath.WalkDir(dir, func(fp string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isImageFile(fp) {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, fp)
+		if err != nil {
+			return err
+		}
+		images = append(images, filepath.ToSlash(rel))
+		return nil
+	})
+	sort.Strings(images)
+	return images, err
+}
@@ -0,0 +1,88 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestCompareDirs(t *testing.T) {
+	actual, expected := t.TempDir(), t.TempDir()
+	for _, dir := range []string{actual, expected} {
+		if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	copyFile(t, FILES["black"], filepath.Join(actual, "same.png"))
+	copyFile(t, FILES["black"], filepath.Join(expected, "same.png"))
+	copyFile(t, FILES["white"], filepath.Join(actual, "sub", "changed.png"))
+	copyFile(t, FILES["black"], filepath.Join(expected, "sub", "changed.png"))
+	copyFile(t, FILES["black"], filepath.Join(actual, "extra.png"))
+	copyFile(t, FILES["black"], filepath.Join(expected, "missing.png"))
+	if err := os.WriteFile(filepath.Join(actual, "notes.txt"), []byte("no image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := defaultConfig()
+	var r DirResult
+	if err := CompareDirs(&c, actual, expected, &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Passed() || !reflect.DeepEqual(r.Missing, []string{"missing.png"}) || !reflect.DeepEqual(r.Extra, []string{"extra.png"}) {
+		t.Fatalf("unexpected missing %v and extra %v images", r.Missing, r.Extra)
+	}
+	if len(r.Comparisons) != 2 || r.Failed != 1 {
+		t.Fatalf("expected 1 of 2 failed comparisons; got %d of %d", r.Failed, len(r.Comparisons))
+	}
+	if r.Comparisons[0].Name != "same.png" || !r.Comparisons[0].Match || r.Comparisons[1].Name != "sub/changed.png" || r.Comparisons[1].Match {
+		t.Fatalf("unexpected comparisons %v", r.Comparisons)
+	}
+
+	var buf bytes.Buffer
+	if err := r.WriteJSON(&buf, actual, expected); err != nil {
+		t.Fatal(err)
+	}
+	var report struct {
+		Passed      bool     `json:"passed"`
+		Failed      int      `json:"failed"`
+		Missing     []string `json:"missing"`
+		Comparisons []struct {
+			Path string `json:"path"`
+		} `json:"comparisons"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Passed || report.Failed != 1 || len(report.Missing) != 1 || len(report.Comparisons) != 2 || report.Comparisons[1].Path != "sub/changed.png" {
+		t.Fatalf("unexpected report %s", buf.String())
+	}
+
+	if err := CompareDirs(&c, filepath.Join(actual, "same.png"), expected, &r); err == nil {
+		t.Fatal("filepath of a file must be rejected as directory")
+	}
+}
+
+func TestCompareDirsPreWait(t *testing.T) {
+	// more pairs than can be compared concurrently
+	actual, expected := t.TempDir(), t.TempDir()
+	for k := 0; k < 2*runtime.NumCPU()+1; k++ {
+		name := fmt.Sprintf("%d.png", k)
+		copyFile(t, FILES["black"], filepath.Join(actual, name))
+		copyFile(t, FILES["black"], filepath.Join(expected, name))
+	}
+	c := defaultConfig()
+	c.PreWait = 100 * time.Millisecond
+	var r DirResult
+	if err := CompareDirs(&c, actual, expected, &r); err != nil {
+		t.Fatal(err)
+	}
+	if !r.Passed() || r.Runtime < c.PreWait || r.Runtime >= 3*c.PreWait {
+		t.Fatalf("pre-wait must be waited for once; got runtime %s", r.Runtime)
+	}
+}
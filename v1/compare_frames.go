@@ -0,0 +1,153 @@
+package v1
+
+import (
+	"fmt"
+	"time"
+)
+
+// FrameResult is the comparison result of one frame of BaseImg with one frame of RefImg
+type FrameResult struct {
+	// BaseFrame is the index of the frame of BaseImg
+	BaseFrame int
+	// RefFrame is the index of the frame of RefImg
+	RefFrame int
+	Score    float64
+	Match    bool
+}
+
+// frames returns all frames of an animated image or the image itself as single frame
+func (i *TaggedImage) frames() []TaggedImage {
+	if len(i.Frames) == 0 {
+		return []TaggedImage{*i}
+	}
+	return i.Frames
+}
+
+// CompareFrames compares all frames of the animated images BaseImg and RefImg
+// (still images are considered animations with one frame). Every frame of BaseImg
+// is compared with every frame of RefImg and the sequences are aligned using dynamic
+// time warping, so sequences of different lengths (e.g. captured with a different frame rate)
+// can be compared. Score is the mean score of the aligned pairs of frames and
+// the animations match iff every aligned pair of frames matches.
+// Frames not decoded yet (see TaggedImage.DecodeFrames) are decoded first.
+func CompareFrames(c *Config, r *Result) error {
+	if err := c.Valid(); err != nil {
+		return err
+	}
+	if err := c.BaseImg.DecodeFrames(); err != nil {
+		return err
+	}
+	if err := c.RefImg.DecodeFrames(); err != nil {
+		return err
+	}
+
+	beforeTime := time.Now()
+	if c.PreWait > time.Duration(0) {
+		time.Sleep(c.PreWait)
+	}
+
+	baseFrames, refFrames := c.BaseImg.frames(), c.RefImg.frames()
+	r.BaseFormat = c.BaseImg.Format
+	r.RefFormat = c.RefImg.Format
+	r.BaseBitDepth = c.BaseImg.BitDepth
+	r.RefBitDepth = c.RefImg.BitDepth
+	r.Config = c.String()
+	r.FrameScores = make([][]float64, len(baseFrames))
+
+	matches := make([][]bool, len(baseFrames))
+	best := FrameResult{Score: 2.0}
+	for b := range baseFrames {
+		r.FrameScores[b] = make([]float64, len(refFrames))
+		matches[b] = make([]bool, len(refFrames))
+		for f := range refFrames {
+			fc := *c
+			fc.BaseImg = baseFrames[b]
+			fc.RefImg = refFrames[f]
+			fc.PreWait = 0
+			if c.Timeout > time.Duration(0) {
+				fc.Timeout = c.Timeout - time.Since(beforeTime) + c.PreWait
+				if fc.Timeout <= 0 {
+					r.Timeout = true
+					r.Runtime = c.PreWait + c.Timeout
+					return fmt.Errorf(`timeout %s exceeded`, c.Timeout)
+				}
+			}
+
+			var fr Result
+			if err := Compare(&fc, &fr); err != nil {
+				if fr.Timeout {
+					r.Timeout = true
+					r.Runtime = c.PreWait + c.Timeout
+				}
+				return fmt.Errorf("frame %d of base image and frame %d of reference image: %s", b, f, err)
+			}
+			r.FrameScores[b][f] = fr.Score
+			matches[b][f] = fr.Match
+			if fr.Score < best.Score {
+				best = FrameResult{b, f, fr.Score, fr.Match}
+			}
+		}
+	}
+
+	r.BestFrame = best
+	r.Frames = nil
+	r.Score = 0.0
+	r.Match = true
+	r.PixelsDifferent = 0
+	for _, pair := range alignFrames(r.FrameScores) {
+		b, f := pair[0], pair[1]
+		r.Frames = append(r.Frames, FrameResult{b, f, r.FrameScores[b][f], matches[b][f]})
+		r.Score += r.FrameScores[b][f]
+		r.Match = r.Match && matches[b][f]
+	}
+	r.Score /= float64(len(r.Frames))
+	r.Timeout = false
+	r.Runtime = time.Since(beforeTime)
+	return nil
+}
+
+// alignFrames computes the warping path of minimal cumulative score through
+// the matrix of frame scores (dynamic time warping). The path starts at the first
+// and ends at the last frames of both sequences; every frame is part of at least one pair
+func alignFrames(scores [][]float64) [][2]int {
+	n, m := len(scores), len(scores[0])
+	cost := make([][]float64, n)
+	for b := range cost {
+		cost[b] = make([]float64, m)
+		for f := range cost[b] {
+			cost[b][f] = scores[b][f]
+			switch {
+			case b == 0 && f == 0:
+			case b == 0:
+				cost[b][f] += cost[b][f-1]
+			case f == 0:
+				cost[b][f] += cost[b-1][f]
+			default:
+				cost[b][f] += min(cost[b-1][f-1], cost[b-1][f], cost[b][f-1])
+			}
+		}
+	}
+
+	// backtrack, preferring diagonal steps
+	path := [][2]int{{n - 1, m - 1}}
+	for b, f := n-1, m-1; b > 0 || f > 0; {
+		switch {
+		case b == 0:
+			f--
+		case f == 0:
+			b--
+		case cost[b-1][f-1] <= cost[b-1][f] && cost[b-1][f-1] <= cost[b][f-1]:
+			b, f = b-1, f-1
+		case cost[b-1][f] <= cost[b][f-1]:
+			b--
+		default:
+			f--
+		}
+		path = append(path, [2]int{b, f})
+	}
+
+	for k := 0; k < len(path)/2; k++ {
+		path[k], path[len(path)-1-k] = path[len(path)-1-k], path[k]
+	}
+	return path
+}
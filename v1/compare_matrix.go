@@ -0,0 +1,116 @@
+package v1
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// MatrixCell is the result of comparing two images of a score matrix
+type MatrixCell struct {
+	// Score gives the score of the comparison. Zero if Mismatch is true
+	Score float64
+	// Match is true iff the images match
+	Match bool
+	// Mismatch is true if the dimensions of the images do not correspond
+	Mismatch bool
+	// Err is the error which occurred while comparing the images. Nil means none
+	Err error
+}
+
+// SkippedImage is a location ComputeMatrix could not read as image
+type SkippedImage struct {
+	// Location gives the location of the file
+	Location string
+	// Err gives the reason why the file was skipped
+	Err error
+}
+
+// MatrixResult is the result of ComputeMatrix
+type MatrixResult struct {
+	// Images lists the locations of all images in the order of rows and columns
+	Images []string
+	// Skipped lists the locations which are not images
+	Skipped []SkippedImage
+	// Cells gives the comparison of the base image Images[row] with the reference image Images[col]
+	// as Cells[row][col]
+	Cells [][]MatrixCell
+	// Runtime gives the duration of loading all images and all comparisons
+	Runtime time.Duration
+}
+
+// ComputeMatrix compares every pair of the images at the given locations in parallel
+// using the options of the given Config (its images are ignored).
+// Locations which cannot be read as image are skipped. Like in the comparison of two images,
+// the alpha channel of the reference image (i.e. the column) weights the pixels by default,
+// so the matrix is not necessarily symmetric.
+// Config.PreWait is waited for once before all comparisons.
+func ComputeMatrix(c *Config, locations []string, r *MatrixResult) error {
+	beforeTime := time.Now()
+	images := make([]TaggedImage, len(locations))
+	errs := make([]error, len(locations))
+	parallelize(len(locations), func(k int) {
+		errs[k] = c.LoadImage(&images[k], locations[k])
+	})
+
+	r.Images, r.Skipped = nil, nil
+	loaded := make([]TaggedImage, 0, len(images))
+	for k, err := range errs {
+		if err != nil {
+			r.Skipped = append(r.Skipped, SkippedImage{Location: locations[k], Err: err})
+			continue
+		}
+		r.Images = append(r.Images, locations[k])
+		loaded = append(loaded, images[k])
+	}
+
+	n := len(loaded)
+	r.Cells = make([][]MatrixCell, n)
+	for row := range r.Cells {
+		r.Cells[row] = make([]MatrixCell, n)
+	}
+	if c.PreWait > time.Duration(0) {
+		time.Sleep(c.PreWait)
+	}
+	compare := Compare
+	if c.Frames {
+		compare = CompareFrames
+	}
+	parallelize(n*n, func(k int) {
+		row, col := k/n, k%n
+		cell := &r.Cells[row][col]
+		pc := *c
+		pc.BaseImg, pc.RefImg = loaded[row], loaded[col]
+		pc.PreWait = 0
+		if pc.BaseImg.Width != pc.RefImg.Width || pc.BaseImg.Height != pc.RefImg.Height {
+			cell.Mismatch = true
+			return
+		}
+		var result Result
+		cell.Err = compare(&pc, &result)
+		cell.Score, cell.Match = result.Score, result.Match
+	})
+
+	r.Runtime = time.Since(beforeTime)
+	return nil
+}
+
+// parallelize calls f for every index from 0 to count-1 with as many goroutines as CPUs
+func parallelize(count int, f func(k int)) {
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.NumCPU() && w < count; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := range indices {
+				f(k)
+			}
+		}()
+	}
+	for k := 0; k < count; k++ {
+		indices <- k
+	}
+	close(indices)
+	wg.Wait()
+}
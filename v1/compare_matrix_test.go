@@ -0,0 +1,104 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestComputeMatrixPreWait(t *testing.T) {
+	// more comparisons than can run concurrently
+	locations := make([]string, runtime.NumCPU()+2)
+	for k := range locations {
+		locations[k] = FILES["black"]
+	}
+	c := defaultConfig()
+	c.PreWait = 100 * time.Millisecond
+	var r MatrixResult
+	if err := ComputeMatrix(&c, locations, &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Runtime < c.PreWait || r.Runtime >= 3*c.PreWait {
+		t.Fatalf("pre-wait must be waited for once; got runtime %s", r.Runtime)
+	}
+}
+
+func TestComputeMatrix(t *testing.T) {
+	locations := []string{FILES["black"], FILES["white"], filepath.Join("../tests", "results_table.adoc"), FILES["grmlf_bs_23"]}
+	c := defaultConfig()
+	var r MatrixResult
+	if err := ComputeMatrix(&c, locations, &r); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Skipped) != 1 || r.Skipped[0].Location != locations[2] {
+		t.Fatalf("non-image must be skipped; got %v", r.Skipped)
+	}
+	if len(r.Images) != 3 || len(r.Cells) != 3 || r.Images[2] != FILES["grmlf_bs_23"] {
+		t.Fatalf("expected 3×3 matrix; got %v", r.Images)
+	}
+	for k := range r.Images {
+		if r.Cells[k][k].Score != 0 || !r.Cells[k][k].Match {
+			t.Fatalf("image %d must match itself; got %v", k, r.Cells[k][k])
+		}
+	}
+	if r.Cells[0][1].Score != 1 || r.Cells[0][1].Match || !r.Cells[0][2].Mismatch || !r.Cells[2][1].Mismatch {
+		t.Fatalf("unexpected cells %v", r.Cells)
+	}
+
+	var adoc bytes.Buffer
+	if err := r.Write(&adoc, "adoc"); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"1. `../tests/black.png`", "|     |1.  |2.  |3.  ", "|1.   |0 %  |100 %  |*     ", "`*` dimensions mismatch"} {
+		if !strings.Contains(adoc.String(), line+"\n") {
+			t.Fatalf("AsciiDoc must contain line '%s'; got\n%s", line, adoc.String())
+		}
+	}
+
+	var md bytes.Buffer
+	if err := r.Write(&md, "md"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(md.String(), "| 1. | 0 % | 100 % | \\* |\n") {
+		t.Fatalf("unexpected Markdown\n%s", md.String())
+	}
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf, "csv"); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 || records[1][0] != FILES["black"] || records[1][2] != "1.000000" || records[1][3] != "" {
+		t.Fatalf("unexpected CSV %v", records)
+	}
+
+	buf.Reset()
+	if err := r.Write(&buf, "json"); err != nil {
+		t.Fatal(err)
+	}
+	var matrix struct {
+		Images  []string     `json:"images"`
+		Scores  [][]*float64 `json:"scores"`
+		Skipped []struct {
+			Location string `json:"location"`
+		} `json:"skipped"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &matrix); err != nil {
+		t.Fatal(err)
+	}
+	if len(matrix.Images) != 3 || matrix.Scores[0][2] != nil || *matrix.Scores[0][1] != 1 || len(matrix.Skipped) != 1 {
+		t.Fatalf("unexpected JSON %s", buf.String())
+	}
+
+	if err := r.Write(&buf, "html"); err == nil {
+		t.Fatal("unknown format must be rejected")
+	}
+}
@@ -0,0 +1,302 @@
+package v1
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DEFAULT_THRESHOLD is the score below (or equal to) which two images are considered to match
+const DEFAULT_THRESHOLD = 0.1
+
+// ssimBlockSize is the edge length of the square windows SSIM is computed on
+const ssimBlockSize = 8
+
+// histogramBins is the number of bins per color channel of the histogram metric
+const histogramBins = 32
+
+// MetricSpec defines one metric of a composite comparison
+type MetricSpec struct {
+	// Name of the metric. Currently supported: {pixel, ssim, histogram}
+	Name string `json:"name"`
+	// ColorSpace to use for the pixel metric. If empty, Config.ColorSpace is used
+	ColorSpace string `json:"colors,omitempty"`
+	// Weight of this metric's score in the combined score. Zero means 1
+	Weight float64 `json:"weight,omitempty"`
+	// Threshold is the maximum score for this metric to match. Nil means DEFAULT_THRESHOLD
+	Threshold *float64 `json:"threshold,omitempty"`
+}
+
+// MetricResult is the result of one metric of a composite comparison
+type MetricResult struct {
+	// Name of the metric
+	Name string
+	// Score of this metric between 0 (inclusively) and 1 (inclusively)
+	Score float64
+	// Weight used for the combined score
+	Weight float64
+	// Threshold used for the verdict
+	Threshold float64
+	// Match is true iff Score does not exceed Threshold
+	Match bool
+}
+
+// String returns the human-readable representation of MetricSpec
+func (m MetricSpec) String() string {
+	threshold := ""
+	if m.Threshold != nil {
+		threshold = strconv.FormatFloat(*m.Threshold, 'g', -1, 64)
+	}
+	return fmt.Sprintf(`%s:%s:%g:%s`, m.Name, m.ColorSpace, m.Weight, threshold)
+}
+
+// Valid returns an error if the metric specification cannot be used
+func (m MetricSpec) Valid() error {
+	switch m.Name {
+	case "pixel", "ssim", "histogram":
+	default:
+		return fmt.Errorf("unknown metric '%s'", m.Name)
+	}
+	if m.ColorSpace != "" && !isColorSpace(m.ColorSpace) {
+		return fmt.Errorf("unknown color space '%s' for metric '%s'", m.ColorSpace, m.Name)
+	}
+	if m.Weight < 0.0 {
+		return fmt.Errorf("weight of metric '%s' must not be negative", m.Name)
+	}
+	if !validThreshold(m.Threshold) {
+		return fmt.Errorf("threshold of metric '%s' must be between 0 and 1", m.Name)
+	}
+	return nil
+}
+
+func (m MetricSpec) weight() float64 {
+	if m.Weight == 0.0 {
+		return 1.0
+	}
+	return m.Weight
+}
+
+func (m MetricSpec) threshold() float64 {
+	return thresholdOrDefault(m.Threshold)
+}
+
+// thresholdOrDefault returns the threshold t points to or DEFAULT_THRESHOLD if t is nil
+func thresholdOrDefault(t *float64) float64 {
+	if t == nil {
+		return DEFAULT_THRESHOLD
+	}
+	return *t
+}
+
+// validThreshold returns true if t is nil or points to a threshold between 0 and 1
+func validThreshold(t *float64) bool {
+	return t == nil || (*t >= 0.0 && *t <= 1.0)
+}
+
+// parseMetricSpecs takes a comma-separated list of metric specifiers
+// like 'pixel:Y'UV:2:0.05,ssim' and returns the corresponding MetricSpecs.
+// Every specifier has the form name[:colors[:weight[:threshold]]].
+func parseMetricSpecs(s string) ([]MetricSpec, error) {
+	errmsg := "invalid metric specifier; expected name[:colors[:weight[:threshold]]]; got '%s'"
+	specs := make([]MetricSpec, 0)
+
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.Split(item, ":")
+		if len(parts) > 4 {
+			return nil, fmt.Errorf(errmsg, item)
+		}
+
+		var m MetricSpec
+		m.Name = strings.ToLower(parts[0])
+		if len(parts) > 1 {
+			m.ColorSpace = parts[1]
+		}
+		if len(parts) > 2 && parts[2] != "" {
+			w, err := strconv.ParseFloat(parts[2], 64)
+			if err != nil {
+				return nil, fmt.Errorf(errmsg, item)
+			}
+			m.Weight = w
+		}
+		if len(parts) > 3 && parts[3] != "" {
+			t, err := strconv.ParseFloat(parts[3], 64)
+			if err != nil {
+				return nil, fmt.Errorf(errmsg, item)
+			}
+			m.Threshold = &t
+		}
+		if err := m.Valid(); err != nil {
+			return nil, err
+		}
+		specs = append(specs, m)
+	}
+
+	return specs, nil
+}
+
+// ssimWindow accumulates alpha-weighted luma statistics of one SSIM window
+type ssimWindow struct {
+	w, x, y, xx, yy, xy float64
+}
+
+func (s *ssimWindow) add(x, y, w float64) {
+	s.w += w
+	s.x += w * x
+	s.y += w * y
+	s.xx += w * x * x
+	s.yy += w * y * y
+	s.xy += w * x * y
+}
+
+// ssim returns the structural similarity index of the window
+// based on https://en.wikipedia.org/wiki/Structural_similarity
+func (s *ssimWindow) ssim() float64 {
+	c1 := math.Pow(0.01*65535, 2)
+	c2 := math.Pow(0.03*65535, 2)
+	muX := s.x / s.w
+	muY := s.y / s.w
+	varX := s.xx/s.w - muX*muX
+	varY := s.yy/s.w - muY*muY
+	covXY := s.xy/s.w - muX*muY
+	return ((2*muX*muY + c1) * (2*covXY + c2)) / ((muX*muX + muY*muY + c1) * (varX + varY + c2))
+}
+
+// histogramBin returns the bin of a 16-bit color channel value
+func histogramBin(v float64) int {
+	bin := int(v) * histogramBins / 65536
+	if bin < 0 {
+		return 0
+	} else if bin >= histogramBins {
+		return histogramBins - 1
+	}
+	return bin
+}
+
+// histogramDistance returns the total variation distance
+// of two non-normalized histograms, averaged over the color channels
+func histogramDistance(h1, h2 *[3][histogramBins]float64) float64 {
+	dist := 0.0
+	for ch := 0; ch < 3; ch++ {
+		sum1, sum2 := 0.0, 0.0
+		for i := 0; i < histogramBins; i++ {
+			sum1 += h1[ch][i]
+			sum2 += h2[ch][i]
+		}
+		if sum1 == 0.0 || sum2 == 0.0 {
+			continue
+		}
+		d := 0.0
+		for i := 0; i < histogramBins; i++ {
+			d += math.Abs(h1[ch][i]/sum1 - h2[ch][i]/sum2)
+		}
+		dist += d / 2
+	}
+	return dist / 3
+}
+
+// compareMetrics corresponds to compareImages, but evaluates all metrics of Config.Metrics.
+// Pixels are visited only once in windows of ssimBlockSize×ssimBlockSize pixels.
+func compareMetrics(c *Config, r *Result, yOffset, yCount int) error {
+	width := c.BaseImg.Width
+	cumuls := make([]float64, len(c.Metrics))
+	var hist1, hist2 [3][histogramBins]float64
+	ssimSum, ssimWeight := 0.0, 0.0
+	r.PixelsDifferent = 0
+
+	for by := yOffset; by < yCount; by += ssimBlockSize {
+		for bx := 0; bx < width; bx += ssimBlockSize {
+			var window ssimWindow
+			for y := by; y < by+ssimBlockSize && y < yCount; y++ {
+				for x := bx; x < bx+ssimBlockSize && x < width; x++ {
+					// NOTE the weight of the pixel depends on AlphaMode
+					r1, g1, b1, a1, r2, g2, b2, a2, alpha := c.samplePixels(x, y)
+					if r1 != r2 || g1 != g2 || b1 != b2 || (c.AlphaMode == "compare-alpha-as-channel" && a1 != a2) {
+						r.PixelsDifferent += 1
+					}
+
+					for i, m := range c.Metrics {
+						if m.Name == "pixel" {
+							d := c.pixelDistance(c.metricColorSpace(m), r1, g1, b1, r2, g2, b2)
+							cumuls[i] += c.alphaDistance(d, a1, a2) * alpha
+						}
+					}
+
+					hist1[0][histogramBin(r1)] += alpha
+					hist1[1][histogramBin(g1)] += alpha
+					hist1[2][histogramBin(b1)] += alpha
+					hist2[0][histogramBin(r2)] += alpha
+					hist2[1][histogramBin(g2)] += alpha
+					hist2[2][histogramBin(b2)] += alpha
+
+					luma1, _, _ := toYUV(r1, g1, b1)
+					luma2, _, _ := toYUV(r2, g2, b2)
+					window.add(luma1, luma2, alpha)
+				}
+			}
+			if window.w > 0.0 {
+				ssimSum += window.ssim() * window.w
+				ssimWeight += window.w
+			}
+		}
+	}
+
+	ssimScore := 0.0
+	if ssimWeight > 0.0 {
+		ssimScore = math.Min(math.Max(1.0-ssimSum/ssimWeight, 0.0), 1.0)
+	}
+	histScore := histogramDistance(&hist1, &hist2)
+
+	r.Metrics = make([]MetricResult, len(c.Metrics))
+	for i, m := range c.Metrics {
+		var score float64
+		switch m.Name {
+		case "pixel":
+			score = pixelScore(cumuls[i], (yCount-yOffset)*width)
+		case "ssim":
+			score = ssimScore
+		case "histogram":
+			score = histScore
+		}
+		r.Metrics[i] = MetricResult{Name: m.Name, Score: score, Weight: m.weight(), Threshold: m.threshold()}
+		r.Metrics[i].Match = score <= r.Metrics[i].Threshold
+	}
+
+	// r.Runtime will be set from the outside
+	r.Timeout = false
+	r.Config = c.String()
+	r.Score, r.Match = combineMetricResults(r.Metrics)
+	return nil
+}
+
+// combineMetricResults returns the weighted mean of the scores
+// and whether all metrics match
+func combineMetricResults(results []MetricResult) (float64, bool) {
+	sum, weights := 0.0, 0.0
+	match := true
+	for _, m := range results {
+		sum += m.Score * m.Weight
+		weights += m.Weight
+		match = match && m.Match
+	}
+	if weights == 0.0 {
+		return 0.0, match
+	}
+	return sum / weights, match
+}
+
+// maxMetricResults returns results with maximum difference for all metrics of Config
+func maxMetricResults(c *Config) []MetricResult {
+	if len(c.Metrics) == 0 {
+		return nil
+	}
+	results := make([]MetricResult, len(c.Metrics))
+	for i, m := range c.Metrics {
+		results[i] = MetricResult{Name: m.Name, Score: 1.0, Weight: m.weight(), Threshold: m.threshold(), Match: false}
+	}
+	return results
+}
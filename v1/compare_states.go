@@ -0,0 +1,200 @@
+package v1
+
+import (
+	"fmt"
+	"time"
+)
+
+// StateEvent is an entry of the timeline of RunStates
+type StateEvent struct {
+	// State gives the name of the state
+	State string
+	// Reached is true if the state appeared in time and in order
+	Reached bool
+	// At gives the duration since the start of RunStates until the state
+	// was reached (or until RunStates gave up waiting for it)
+	At time.Duration
+	// Attempts gives the number of screenshots compared with the state
+	Attempts int
+	// Ref gives the source of the reference image with the lowest score of the last attempt
+	Ref string
+	// Score gives the lowest score of the last attempt
+	Score float64
+}
+
+// StatesResult is the result of RunStates
+type StatesResult struct {
+	// Timeline lists the states in order, up to the first state which was not reached
+	Timeline []StateEvent
+	// Passed is true iff all states were reached in order within their timeouts
+	Passed bool
+	// Failure describes why the sequence did not pass. Empty if Passed is true
+	Failure string
+	// Runtime gives the duration of the whole run plus waiting times
+	Runtime time.Duration
+}
+
+// stateRefs holds the loaded reference images of a State
+type stateRefs struct {
+	state State
+	refs  []TaggedImage
+}
+
+// RunStates repeatedly captures screenshots from StateSequence.Source (or, if empty,
+// from the location BaseImg was read from) and verifies that the states appear in order:
+// every state must be reached within its timeout after the previous state was reached.
+// A state is skipped, i.e. the sequence fails, if a later state matches before it.
+// Config.RefImg is ignored. Images with other dimensions than a reference image do not match it.
+// Config.PreWait is waited for once before the first capture.
+//
+// A sequence which does not pass is not an error; StatesResult.Passed is false then.
+// Failing to capture a screenshot is retried until the timeout of the current state passes.
+func RunStates(c *Config, s *StateSequence, r *StatesResult) error {
+	location := s.Source
+	if location == "" {
+		location = c.BaseImg.location
+	}
+	if location == "" || location == "-" {
+		return fmt.Errorf(`state sequence requires a source to capture screenshots from`)
+	}
+	if len(s.States) == 0 {
+		return fmt.Errorf(`state sequence declares no states`)
+	}
+
+	states := make([]stateRefs, len(s.States))
+	for k, state := range s.States {
+		states[k].state = state
+		var mask TaggedImage
+		if state.Mask != "" {
+			if err := c.LoadImage(&mask, state.Mask); err != nil {
+				return err
+			}
+		}
+		for _, ref := range state.Refs {
+			var img TaggedImage
+			if err := c.LoadImage(&img, ref); err != nil {
+				return err
+			}
+			if state.Mask != "" {
+				if err := img.ApplyMask(&mask); err != nil {
+					return err
+				}
+			}
+			states[k].refs = append(states[k].refs, img)
+		}
+	}
+
+	beforeTime := time.Now()
+	if c.PreWait > time.Duration(0) {
+		time.Sleep(c.PreWait)
+	}
+	interval := s.Interval
+	if interval == 0 {
+		interval = c.Interval
+	}
+	if interval == 0 {
+		interval = DEFAULT_INTERVAL
+	}
+
+	ac := *c
+	ac.PreWait = 0
+	ac.NoDimensionError = true
+	compare := Compare
+	if c.Frames {
+		compare = CompareFrames
+	}
+
+	// matchState compares the screenshot in ac.BaseImg with all reference images of a state
+	matchState := func(st *stateRefs) (StateEvent, error) {
+		event := StateEvent{State: st.state.Name, Score: 1.0}
+		sc := ac
+		if st.state.Threshold != nil {
+			sc.Threshold = st.state.Threshold
+		}
+		for _, ref := range st.refs {
+			var result Result
+			sc.RefImg = ref
+			if err := compare(&sc, &result); err != nil {
+				return event, err
+			}
+			if result.Score <= event.Score || event.Ref == "" {
+				event.Ref, event.Score = ref.Source, result.Score
+			}
+			if result.Match {
+				event.Reached = true
+			}
+		}
+		return event, nil
+	}
+
+	r.Timeline = nil
+	r.Passed = false
+	r.Failure = ""
+	since := time.Now()
+	for k := range states {
+		timeout := states[k].state.Timeout
+		if timeout == 0 {
+			timeout = c.Deadline
+		}
+		var deadline time.Time
+		if timeout > 0 {
+			deadline = since.Add(timeout)
+		}
+
+		event := StateEvent{State: states[k].state.Name}
+		var lastErr error
+	attempts:
+		for {
+			attemptTime := time.Now()
+			lastErr = ac.LoadImage(&ac.BaseImg, location)
+			if lastErr == nil {
+				attempt, err := matchState(&states[k])
+				if err != nil {
+					return err
+				}
+				attempt.Attempts = event.Attempts + 1
+				event = attempt
+				if event.Reached {
+					break attempts
+				}
+				for _, later := range states[k+1:] {
+					skipped, err := matchState(&later)
+					if err != nil {
+						return err
+					}
+					if skipped.Reached {
+						event.At = time.Since(beforeTime)
+						r.Timeline = append(r.Timeline, event)
+						r.Failure = fmt.Sprintf("state '%s' appeared before state '%s'", later.state.Name, event.State)
+						r.Runtime = time.Since(beforeTime)
+						return nil
+					}
+				}
+			}
+
+			// the last attempt takes place at the deadline
+			next := attemptTime.Add(interval)
+			if !deadline.IsZero() && !attemptTime.Before(deadline) {
+				event.At = time.Since(beforeTime)
+				r.Timeline = append(r.Timeline, event)
+				r.Failure = fmt.Sprintf("state '%s' not reached within %s", event.State, timeout)
+				if event.Attempts == 0 && lastErr != nil {
+					r.Failure += fmt.Sprintf(": %s", lastErr)
+				}
+				r.Runtime = time.Since(beforeTime)
+				return nil
+			} else if !deadline.IsZero() && next.After(deadline) {
+				next = deadline
+			}
+			time.Sleep(time.Until(next))
+		}
+
+		since = time.Now()
+		event.At = since.Sub(beforeTime)
+		r.Timeline = append(r.Timeline, event)
+	}
+
+	r.Passed = true
+	r.Runtime = time.Since(beforeTime)
+	return nil
+}
@@ -0,0 +1,127 @@
+package v1
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// stateSequence returns a sequence of states with one reference image each
+func stateSequence(source string, timeout time.Duration, names ...string) *StateSequence {
+	s := &StateSequence{Source: source, Interval: 10 * time.Millisecond}
+	for _, name := range names {
+		s.States = append(s.States, State{Name: name, Refs: []string{FILES[name]}, Timeout: timeout})
+	}
+	return s
+}
+
+// showScreens copies the given images to screen one after another
+func showScreens(t *testing.T, screen string, delay time.Duration, names ...string) {
+	for _, name := range names {
+		time.Sleep(delay)
+		copyFile(t, FILES[name], screen)
+	}
+}
+
+func TestRunStates(t *testing.T) {
+	screen := filepath.Join(t.TempDir(), "screen.png")
+	copyFile(t, FILES["black"], screen)
+	go showScreens(t, screen, 80*time.Millisecond, "white", "blue")
+
+	c := defaultConfig()
+	var r StatesResult
+	if err := RunStates(&c, stateSequence(screen, 5*time.Second, "black", "white", "blue"), &r); err != nil {
+		t.Fatal(err)
+	}
+	if !r.Passed || r.Failure != "" || len(r.Timeline) != 3 {
+		t.Fatalf("all states must be reached; got %v (%s)", r.Timeline, r.Failure)
+	}
+	for k, event := range r.Timeline {
+		if !event.Reached || event.Score != 0 || event.Attempts < 1 || (k > 0 && event.At <= r.Timeline[k-1].At) {
+			t.Fatalf("unexpected timeline %v", r.Timeline)
+		}
+	}
+}
+
+func TestRunStatesFailures(t *testing.T) {
+	screen := filepath.Join(t.TempDir(), "screen.png")
+	copyFile(t, FILES["black"], screen)
+	go showScreens(t, screen, 80*time.Millisecond, "white")
+
+	// state "white" appears before state "blue"
+	c := defaultConfig()
+	var r StatesResult
+	if err := RunStates(&c, stateSequence(screen, 5*time.Second, "black", "blue", "white"), &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Passed || len(r.Timeline) != 2 || !r.Timeline[0].Reached || r.Timeline[1].Reached || r.Timeline[1].State != "blue" {
+		t.Fatalf("skipped state must fail the sequence; got %v (%s)", r.Timeline, r.Failure)
+	}
+
+	// state "blue" never appears
+	if err := RunStates(&c, stateSequence(screen, 100*time.Millisecond, "white", "blue"), &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Passed || len(r.Timeline) != 2 || r.Timeline[1].Attempts < 2 || r.Timeline[1].Ref != FILES["blue"] {
+		t.Fatalf("state must time out; got %v (%s)", r.Timeline, r.Failure)
+	}
+}
+
+func TestStateSequenceFromFile(t *testing.T) {
+	dir := t.TempDir()
+	yamlSpec := `source: vnc://localhost:5900
+interval: 500i
+states:
+  - name: grub
+    refs: [grub.png, /refs/grub_old.png]
+    timeout: 30s
+  - refs: ["refs.zip!/login.png"]
+    mask: login_mask.png
+    threshold: 0.05
+`
+	jsonSpec := `{"source": "screen.png", "states": [{"name": "login", "refs": ["raw:login.raw"], "timeout": "2m"}]}`
+	if err := os.WriteFile(filepath.Join(dir, "boot.yaml"), []byte(yamlSpec), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "boot.json"), []byte(jsonSpec), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var s StateSequence
+	if err := s.FromFile(filepath.Join(dir, "boot.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	if s.Source != "vnc://localhost:5900" || s.Interval != 500*time.Millisecond || len(s.States) != 2 {
+		t.Fatalf("unexpected state sequence %v", s)
+	}
+	grub, login := s.States[0], s.States[1]
+	if grub.Name != "grub" || grub.Timeout != 30*time.Second || grub.Refs[0] != filepath.Join(dir, "grub.png") || grub.Refs[1] != "/refs/grub_old.png" {
+		t.Fatalf("unexpected state %v", grub)
+	}
+	if login.Name != "state 2" || login.Threshold == nil || *login.Threshold != 0.05 || login.Refs[0] != filepath.Join(dir, "refs.zip!/login.png") || login.Mask != filepath.Join(dir, "login_mask.png") {
+		t.Fatalf("unexpected state %v", login)
+	}
+
+	if err := s.FromFile(filepath.Join(dir, "boot.json")); err != nil {
+		t.Fatal(err)
+	}
+	if s.Source != filepath.Join(dir, "screen.png") || s.States[0].Timeout != 2*time.Minute || s.States[0].Refs[0] != RAW_LOCATION_PREFIX+filepath.Join(dir, "login.raw") {
+		t.Fatalf("unexpected state sequence %v", s)
+	}
+
+	typo := `{"source": "screen.png", "states": [{"refs": ["login.png"], "treshold": 0.05}]}`
+	if err := os.WriteFile(filepath.Join(dir, "typo.json"), []byte(typo), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.FromFile(filepath.Join(dir, "typo.json")); err == nil {
+		t.Fatal("unknown JSON key 'treshold' must be rejected")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "empty.yml"), []byte("states: []\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.FromFile(filepath.Join(dir, "empty.yml")); err == nil {
+		t.Fatal("state sequence without states must be rejected")
+	}
+}
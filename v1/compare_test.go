@@ -1,9 +1,15 @@
 package v1
 
 import (
+	"image"
+	"image/color"
+	"math"
+	"os"
 	"path/filepath"
 	"testing"
 	"time"
+
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
 )
 
 var FILES map[string]string
@@ -14,6 +20,7 @@ func init() {
 
 	FILES["black"] = "black.png"
 	FILES["white"] = "white.png"
+	FILES["blue"] = "blue.png"
 	FILES["g"] = "google_query_screenshot.png"
 	FILES["g_transparent"] = "google_query_transparent.png"
 	FILES["grmlforensic_website"] = "grmlforensic_contact_website.png"
@@ -100,6 +107,104 @@ func TestDifferentImages(t *testing.T) {
 	}
 }
 
+func TestZeroThreshold(t *testing.T) {
+	s := defaultConfig()
+	var r Result
+	if err := s.BaseImg.FromFilepath(FILES["grml_kB"]); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RefImg.FromFilepath(FILES["grml_MB"]); err != nil {
+		t.Fatal(err)
+	}
+	if err := Compare(&s, &r); err != nil {
+		t.Fatal(err)
+	}
+	if !r.Match {
+		t.Fatalf("similar images must match with DEFAULT_THRESHOLD; got %f", r.Score)
+	}
+
+	zero := 0.0
+	s.Threshold = &zero
+	if err := Compare(&s, &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Match {
+		t.Fatalf("threshold 0 must only accept identical images; got match with %f", r.Score)
+	}
+
+	specs, err := parseMetricSpecs("pixel:::0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if specs[0].Threshold == nil || specs[0].threshold() != 0.0 {
+		t.Fatalf("metric threshold 0 must be kept; got %s", specs[0])
+	}
+
+	c := NewConfig()
+	if _, err := c.FromArgs([]string{"screenshot-compare", "--threshold", "0", FILES["grml_kB"], FILES["grml_MB"]}, "", 2); err != nil {
+		t.Fatal(err)
+	}
+	if c.Threshold == nil || *c.Threshold != 0.0 {
+		t.Fatalf("CLI argument --threshold 0 must be kept; got %s", c.String())
+	}
+}
+
+func TestFlagsKeepEnvAndJSON(t *testing.T) {
+	t.Setenv(`SCMP_COLORS`, `Y'UV`)
+	t.Setenv(`SCMP_THRESHOLD`, `0.3`)
+	// images of environment and JSON file are never loaded
+	t.Setenv(`SCMP_BASEIMG`, `missing.png`)
+	jsonFile := filepath.Join(t.TempDir(), "conf.json")
+	if err := os.WriteFile(jsonFile, []byte(`{"timeout": "5s", "frames": true, "refimg": "vnc://localhost:1"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// CLI commands read options in this order
+	c := NewConfig()
+	cli := kingpin.New("screenshot-compare", "")
+	flags := RegisterFlags(cli)
+	if err := ParseArgs(cli, []string{"--diffpixel", "2"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.OptionsFromEnv(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.OptionsFromJSON(jsonFile, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.FromFlags(flags, 3); err != nil {
+		t.Fatal(err)
+	}
+	if c.ColorSpace != `Y'UV` || c.threshold() != 0.3 || c.Timeout != 5*time.Second || !c.Frames || c.AdmissibleDiffPixel != 2 {
+		t.Fatalf("unset flags must keep options of environment and JSON file; got %s", c.String())
+	}
+	if c.BaseImg.Image != nil || c.RefImg.Image != nil {
+		t.Fatalf("options must not load images; got %s", c.String())
+	}
+	if err := c.ValidOptions(); err != nil {
+		t.Fatal(err)
+	}
+
+	// variables set to false override true values of sources with lower precedence
+	t.Setenv(`SCMP_FRAMES`, `false`)
+	t.Setenv(`SCMP_ORIENT`, `false`)
+	t.Setenv(`SCMP_ICC`, `false`)
+	c.Orient, c.ICC = true, true
+	if _, err := c.OptionsFromEnv(); err != nil {
+		t.Fatal(err)
+	}
+	if c.Frames || c.Orient || c.ICC {
+		t.Fatalf("SCMP_FRAMES, SCMP_ORIENT and SCMP_ICC set to false must be stored; got %s", c.String())
+	}
+
+	// without flag, the color space defaults to RGB
+	c = NewConfig()
+	c.ColorSpace = `Y'UV`
+	if _, err := c.FromFlags(flags, 2); err != nil || c.ColorSpace != `RGB` {
+		t.Fatalf("expected color space RGB; got %s, %v", c.ColorSpace, err)
+	}
+}
+
 func TestTotallyDifferentImages(t *testing.T) {
 	s := defaultConfig()
 	var r Result
@@ -171,3 +276,220 @@ func TestTransparency(t *testing.T) {
 		t.Fatalf("Base image must match given transparent reference image; got difference of %f", r.Score)
 	}
 }
+
+func TestMetricSpecParser(t *testing.T) {
+	specs, err := parseMetricSpecs("pixel:Y'UV:2:0.05, ssim,histogram::0.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(specs) != 3 {
+		t.Fatalf("expected 3 metric specs; got %d", len(specs))
+	}
+	if specs[0].String() != "pixel:Y'UV:2:0.05" {
+		t.Fatalf("unexpected metric spec %s", specs[0])
+	}
+	if specs[1].Name != "ssim" || specs[1].Threshold != nil || specs[2].Weight != 0.5 {
+		t.Fatalf("unexpected metric specs %v", specs)
+	}
+
+	for _, invalid := range []string{"unknown", "pixel:HSV", "ssim::x", "pixel:RGB:1:2", "a:b:c:d:e"} {
+		if _, err := parseMetricSpecs(invalid); err == nil {
+			t.Fatalf("metric specifier '%s' must be rejected", invalid)
+		}
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	s := defaultConfig()
+	s.Metrics = []MetricSpec{{Name: "pixel"}, {Name: "ssim", Weight: 2}, {Name: "histogram"}}
+	var r1, r2 Result
+
+	if err := s.BaseImg.FromFilepath(FILES["g"]); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RefImg.FromFilepath(FILES["g"]); err != nil {
+		t.Fatal(err)
+	}
+	if err := Compare(&s, &r1); err != nil {
+		t.Fatal(err)
+	}
+	if len(r1.Metrics) != 3 {
+		t.Fatalf("expected 3 metric results; got %d", len(r1.Metrics))
+	}
+	for _, m := range r1.Metrics {
+		if m.Score > 0.01 || !m.Match {
+			t.Fatalf("Same image must return difference %f for metric %s; got %f", 0.0, m.Name, m.Score)
+		}
+	}
+	if !r1.Match {
+		t.Fatalf("Same image must match")
+	}
+
+	if err := s.BaseImg.FromFilepath(FILES["black"]); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RefImg.FromFilepath(FILES["white"]); err != nil {
+		t.Fatal(err)
+	}
+	if err := Compare(&s, &r2); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range r2.Metrics {
+		if m.Score <= 0.9 || m.Match {
+			t.Fatalf("Totally different images must return very high difference for metric %s; got %f", m.Name, m.Score)
+		}
+	}
+	if r2.Match {
+		t.Fatalf("Totally different images must not match")
+	}
+}
+
+func TestPixelMetricCorrespondsToScore(t *testing.T) {
+	s := defaultConfig()
+	var r1, r2 Result
+	if err := s.BaseImg.FromFilepath(FILES["grmlf_bo_back"]); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RefImg.FromFilepath(FILES["grmlf_bs_transparent"]); err != nil {
+		t.Fatal(err)
+	}
+	if err := Compare(&s, &r1); err != nil {
+		t.Fatal(err)
+	}
+	s.Metrics = []MetricSpec{{Name: "pixel"}}
+	if err := Compare(&s, &r2); err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(r1.Score-r2.Score) > 1e-9 || r1.PixelsDifferent != r2.PixelsDifferent {
+		t.Fatalf("pixel metric must correspond to default score; got %f and %f", r1.Score, r2.Score)
+	}
+}
+
+func TestGrayAndSingleChannel(t *testing.T) {
+	s := defaultConfig()
+	var r Result
+	if err := s.BaseImg.FromFilepath(FILES["black"]); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RefImg.FromFilepath(FILES["white"]); err != nil {
+		t.Fatal(err)
+	}
+	for _, colorSpace := range []string{"Gray", "R", "G", "B", "Y"} {
+		s.ColorSpace = colorSpace
+		if err := Compare(&s, &r); err != nil {
+			t.Fatal(err)
+		}
+		if r.Score <= 0.9 {
+			t.Fatalf("Totally different images must return very high difference in %s; got %f", colorSpace, r.Score)
+		}
+	}
+
+	// blue (#0046c7) does not differ from black in channel R
+	if err := s.RefImg.FromFilepath(FILES["blue"]); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]bool{"R": false, "G": true, "B": true, "Gray": true}
+	for colorSpace, different := range expected {
+		s.ColorSpace = colorSpace
+		if err := Compare(&s, &r); err != nil {
+			t.Fatal(err)
+		}
+		if different != (r.Score > 0.0) {
+			t.Fatalf("Unexpected score for black/blue in %s; got %f", colorSpace, r.Score)
+		}
+	}
+
+	// BT.709 uses different weights than BT.601
+	s.ColorSpace = "Gray"
+	if err := Compare(&s, &r); err != nil {
+		t.Fatal(err)
+	}
+	bt601 := r.Score
+	s.Luma = "BT.709"
+	if err := Compare(&s, &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Score == bt601 {
+		t.Fatalf("Expecting a difference between BT.709 and BT.601 for black/blue; got %f and %f", r.Score, bt601)
+	}
+}
+
+func TestAlphaModes(t *testing.T) {
+	transparent := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	transparent.Set(0, 0, color.NRGBA{0, 0, 0, 0})
+	transparent.Set(1, 0, color.NRGBA{0, 0, 0, 0})
+	opaque := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	opaque.Set(0, 0, color.NRGBA{0xFF, 0xFF, 0xFF, 0xFF})
+	opaque.Set(1, 0, color.NRGBA{0xFF, 0xFF, 0xFF, 0xFF})
+
+	s := defaultConfig()
+	s.BaseImg = TaggedImage{Image: transparent, Width: 2, Height: 1}
+	s.RefImg = TaggedImage{Image: opaque, Width: 2, Height: 1}
+
+	expected := map[string]bool{
+		"ref-mask":                  true,
+		"both-mask":                 false,
+		"composite-over-background": false,
+		"compare-alpha-as-channel":  true,
+	}
+	for mode, different := range expected {
+		var r Result
+		s.AlphaMode = mode
+		if err := Compare(&s, &r); err != nil {
+			t.Fatal(err)
+		}
+		if different != (r.Score > 0.5) {
+			t.Fatalf("Unexpected score for alpha mode %s; got %f", mode, r.Score)
+		}
+	}
+
+	// transparent base over black background differs from white reference
+	var r Result
+	s.AlphaMode = "composite-over-background"
+	s.Background = color.NRGBA{0, 0, 0, 0xFF}
+	if err := Compare(&s, &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Score <= 0.9 {
+		t.Fatalf("Transparent image over black background must differ from white; got %f", r.Score)
+	}
+
+	s.Background = color.NRGBA{0, 0, 0, 0x80}
+	if err := Compare(&s, &r); err == nil {
+		t.Fatalf("Non-opaque background must be rejected")
+	}
+
+	if _, err := parseColorSpecifier("#00ff00"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseColorSpecifier("green"); err == nil {
+		t.Fatalf("color specifier 'green' must be rejected")
+	}
+}
+
+func TestLinear(t *testing.T) {
+	dark := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+	dark.Set(0, 0, color.NRGBA{0x10, 0x10, 0x10, 0xFF})
+	darker := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+	darker.Set(0, 0, color.NRGBA{0x08, 0x08, 0x08, 0xFF})
+
+	s := defaultConfig()
+	s.BaseImg = TaggedImage{Image: dark, Width: 1, Height: 1}
+	s.RefImg = TaggedImage{Image: darker, Width: 1, Height: 1}
+
+	var r1, r2 Result
+	if err := Compare(&s, &r1); err != nil {
+		t.Fatal(err)
+	}
+	s.Linear = true
+	if err := Compare(&s, &r2); err != nil {
+		t.Fatal(err)
+	}
+	if r2.Score >= r1.Score {
+		t.Fatalf("Expecting dark colors to differ less in linear light; got %f (linear) and %f", r2.Score, r1.Score)
+	}
+
+	if toLinear(0.0) != 0.0 || math.Abs(toLinear(65535.0)-65535.0) > 1e-6 {
+		t.Fatalf("sRGB linearisation must preserve black and white")
+	}
+}
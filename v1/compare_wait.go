@@ -0,0 +1,96 @@
+package v1
+
+import (
+	"fmt"
+	"time"
+)
+
+// DEFAULT_INTERVAL is the duration between two attempts of WaitForMatch if Config.Interval is zero
+const DEFAULT_INTERVAL = 1 * time.Second
+
+// WaitResult is the result of WaitForMatch
+type WaitResult struct {
+	// Result is the result of the last comparison
+	Result
+	// Attempts gives the number of comparisons
+	Attempts int
+	// Scores gives the score of every comparison in chronological order
+	Scores []float64
+}
+
+// WaitForMatch repeatedly re-acquires BaseImg from the location it was read from
+// (e.g. a file which is overwritten or a capture source like 'vnc://host:5900')
+// and compares it with RefImg until the images match or Config.Deadline passes.
+// Config.Interval defines the duration between the beginnings of two attempts.
+// If Config.Frames is set, attempts use CompareFrames instead of Compare.
+//
+// Not matching until the deadline is not an error; Result.Match is false then.
+// Failing to re-acquire BaseImg or to compare the images (e.g. exceeding Config.Timeout
+// or different dimensions while the screen resolution changes) is retried until the deadline passes.
+func WaitForMatch(c *Config, r *WaitResult) error {
+	if err := c.Valid(); err != nil {
+		return err
+	}
+	location := c.BaseImg.location
+	if location == "" || location == "-" {
+		return fmt.Errorf(`base image '%s' cannot be re-acquired; read it from a filepath or capture source`, c.BaseImg.Source)
+	}
+
+	beforeTime := time.Now()
+	if c.PreWait > time.Duration(0) {
+		time.Sleep(c.PreWait)
+	}
+	interval := c.Interval
+	if interval == 0 {
+		interval = DEFAULT_INTERVAL
+	}
+	var deadline time.Time
+	if c.Deadline > 0 {
+		deadline = beforeTime.Add(c.PreWait + c.Deadline)
+	}
+
+	ac := *c
+	ac.PreWait = 0
+	compare := Compare
+	if c.Frames {
+		compare = CompareFrames
+	}
+
+	r.Attempts = 0
+	r.Scores = nil
+	var lastErr error
+	for attempt := 0; ; attempt++ {
+		attemptTime := time.Now()
+		if attempt > 0 {
+			lastErr = ac.LoadImage(&ac.BaseImg, location)
+		}
+		var result Result
+		if lastErr == nil {
+			lastErr = compare(&ac, &result)
+		}
+		if lastErr == nil {
+			r.Result = result
+			r.Attempts++
+			r.Scores = append(r.Scores, r.Score)
+			if r.Match {
+				break
+			}
+		}
+
+		// the last attempt takes place at the deadline
+		next := attemptTime.Add(interval)
+		if !deadline.IsZero() && !attemptTime.Before(deadline) {
+			if r.Attempts == 0 {
+				return fmt.Errorf("deadline %s exceeded without comparing base image: %s", c.Deadline, lastErr)
+			}
+			break
+		} else if !deadline.IsZero() && next.After(deadline) {
+			next = deadline
+		}
+		time.Sleep(time.Until(next))
+	}
+
+	c.BaseImg = ac.BaseImg
+	r.Runtime = time.Since(beforeTime)
+	return nil
+}
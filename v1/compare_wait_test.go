@@ -0,0 +1,122 @@
+package v1
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// copyFile atomically replaces dst with a copy of src
+func copyFile(t *testing.T, src, dst string) {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	tmp := dst + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWaitForMatch(t *testing.T) {
+	screen := filepath.Join(t.TempDir(), "screen.png")
+	copyFile(t, FILES["black"], screen)
+
+	c := defaultConfig()
+	c.Interval = 20 * time.Millisecond
+	c.Deadline = 10 * time.Second
+	if err := c.LoadImage(&c.BaseImg, screen); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.LoadImage(&c.RefImg, FILES["white"]); err != nil {
+		t.Fatal(err)
+	}
+
+	// the system under test reaches the expected state after a while
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		copyFile(t, FILES["white"], screen)
+	}()
+
+	var r WaitResult
+	if err := WaitForMatch(&c, &r); err != nil {
+		t.Fatal(err)
+	}
+	if !r.Match || r.Score != 0 {
+		t.Fatalf("images must match eventually; got score %f", r.Score)
+	}
+	if r.Attempts < 2 || len(r.Scores) != r.Attempts || r.Scores[0] != 1 || r.Scores[r.Attempts-1] != 0 {
+		t.Fatalf("unexpected attempts %d with scores %v", r.Attempts, r.Scores)
+	}
+}
+
+func TestWaitForMatchCompareError(t *testing.T) {
+	// the screen resolution changes before the expected state is reached
+	screen := filepath.Join(t.TempDir(), "screen.png")
+	copyFile(t, FILES["g"], screen)
+
+	c := defaultConfig()
+	c.Interval = 20 * time.Millisecond
+	c.Deadline = 10 * time.Second
+	if err := c.LoadImage(&c.BaseImg, screen); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.LoadImage(&c.RefImg, FILES["white"]); err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		copyFile(t, FILES["white"], screen)
+	}()
+
+	var r WaitResult
+	if err := WaitForMatch(&c, &r); err != nil {
+		t.Fatalf("failed comparisons must be retried; got %s", err)
+	}
+	if !r.Match || r.Attempts != len(r.Scores) {
+		t.Fatalf("images must match eventually; got %d attempts with scores %v", r.Attempts, r.Scores)
+	}
+
+	// failing until the deadline is an error
+	c.Deadline = 100 * time.Millisecond
+	if err := c.LoadImage(&c.BaseImg, FILES["g"]); err != nil {
+		t.Fatal(err)
+	}
+	if err := WaitForMatch(&c, &r); err == nil || !strings.Contains(err.Error(), "dimensions") {
+		t.Fatalf("expected error of the last comparison; got %v", err)
+	}
+}
+
+func TestWaitForMatchDeadline(t *testing.T) {
+	c := defaultConfig()
+	c.Interval = 20 * time.Millisecond
+	c.Deadline = 150 * time.Millisecond
+	if err := c.LoadImage(&c.BaseImg, FILES["black"]); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.LoadImage(&c.RefImg, FILES["white"]); err != nil {
+		t.Fatal(err)
+	}
+
+	var r WaitResult
+	if err := WaitForMatch(&c, &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Match || r.Attempts < 2 || r.Runtime < c.Deadline-c.Interval {
+		t.Fatalf("images must not match until the deadline; got %d attempts in %s", r.Attempts, r.Runtime)
+	}
+
+	// images without location cannot be re-acquired
+	c.BaseImg.FromImage(c.BaseImg.Image, "memory")
+	if err := WaitForMatch(&c, &r); err == nil {
+		t.Fatal("image without location must be rejected")
+	}
+}
@@ -0,0 +1,280 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"time"
+)
+
+// disposal operations applied to the frame area after a frame has been displayed
+const (
+	disposeNone = iota
+	disposeBackground
+	disposePrevious
+)
+
+// animationFrame is a frame of an animation before compositing
+type animationFrame struct {
+	img     image.Image
+	rect    image.Rectangle
+	blend   draw.Op
+	dispose int
+	delay   time.Duration
+}
+
+// DecodeFrames decodes all frames of an animated GIF or APNG image read by FromReader into Frames.
+// Compositing frames is expensive, so they are only decoded on request.
+// The encoded data is kept in memory until then. Config.LoadImage calls DecodeFrames
+// if Config.Frames is set and discards the encoded data otherwise
+func (i *TaggedImage) DecodeFrames() error {
+	if i.encoded == nil {
+		return nil
+	}
+	frames, err := decodeFrames(i.encoded, i.Format, i.Source, i.BitDepth)
+	if err != nil {
+		return err
+	}
+	i.Frames = frames
+	i.encoded = nil
+	return nil
+}
+
+// decodeFrames decodes all frames of an animated GIF or APNG image.
+// It returns nil for still images and other formats
+func decodeFrames(data []byte, format string, source string, bitDepth int) ([]TaggedImage, error) {
+	var width, height int
+	var frames []animationFrame
+	var err error
+
+	switch format {
+	case "gif":
+		width, height, frames, err = gifFrames(data)
+	case "png":
+		width, height, frames, err = apngFrames(data)
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode frames of '%s': %s", source, err)
+	}
+	if len(frames) < 2 {
+		return nil, nil
+	}
+
+	composited := composeFrames(width, height, frames)
+	tagged := make([]TaggedImage, len(composited))
+	for k, img := range composited {
+		tagged[k].FromImage(img, fmt.Sprintf("%s[%d]", source, k))
+		tagged[k].Format = format
+		tagged[k].BitDepth = bitDepth
+		tagged[k].Delay = frames[k].delay
+	}
+	return tagged, nil
+}
+
+// composeFrames renders every frame onto a transparent canvas of the given size
+// considering blend and disposal operations of the preceding frames
+func composeFrames(width, height int, frames []animationFrame) []*image.RGBA {
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+	composited := make([]*image.RGBA, 0, len(frames))
+
+	for _, f := range frames {
+		var previous *image.RGBA
+		if f.dispose == disposePrevious {
+			previous = image.NewRGBA(canvas.Bounds())
+			copy(previous.Pix, canvas.Pix)
+		}
+
+		draw.Draw(canvas, f.rect, f.img, f.img.Bounds().Min, f.blend)
+		output := image.NewRGBA(canvas.Bounds())
+		copy(output.Pix, canvas.Pix)
+		composited = append(composited, output)
+
+		switch f.dispose {
+		case disposeBackground:
+			draw.Draw(canvas, f.rect, image.Transparent, image.Point{}, draw.Src)
+		case disposePrevious:
+			draw.Draw(canvas, f.rect, previous, f.rect.Min, draw.Src)
+		}
+	}
+	return composited
+}
+
+// gifFrames returns the canvas size and all frames of a GIF image
+func gifFrames(data []byte) (int, int, []animationFrame, error) {
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	frames := make([]animationFrame, len(g.Image))
+	for k, img := range g.Image {
+		frames[k] = animationFrame{img: img, rect: img.Bounds(), blend: draw.Over, delay: time.Duration(g.Delay[k]) * 10 * time.Millisecond}
+		switch g.Disposal[k] {
+		case gif.DisposalBackground:
+			frames[k].dispose = disposeBackground
+		case gif.DisposalPrevious:
+			frames[k].dispose = disposePrevious
+		}
+	}
+	return g.Config.Width, g.Config.Height, frames, nil
+}
+
+// pngChunk is a chunk of a PNG file
+type pngChunk struct {
+	typ  string
+	data []byte
+}
+
+// apngFrames returns the canvas size and all frames of an APNG image
+// as specified at https://wiki.mozilla.org/APNG_Specification.
+// PNG files without acTL chunk yield no frames
+func apngFrames(data []byte) (int, int, []animationFrame, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return 0, 0, nil, fmt.Errorf("missing PNG signature")
+	}
+
+	// split into chunks
+	var chunks []pngChunk
+	for rest := data[len(pngSignature):]; len(rest) >= 12; {
+		length := int(binary.BigEndian.Uint32(rest[0:4]))
+		if length < 0 || len(rest) < 12+length {
+			return 0, 0, nil, fmt.Errorf("truncated PNG chunk")
+		}
+		chunks = append(chunks, pngChunk{string(rest[4:8]), rest[8 : 8+length]})
+		rest = rest[12+length:]
+	}
+	if len(chunks) == 0 || chunks[0].typ != "IHDR" || len(chunks[0].data) != 13 {
+		return 0, 0, nil, fmt.Errorf("missing IHDR chunk")
+	}
+	ihdr := chunks[0].data
+	width, height := int(binary.BigEndian.Uint32(ihdr[0:4])), int(binary.BigEndian.Uint32(ihdr[4:8]))
+
+	animated := false
+	for _, c := range chunks {
+		animated = animated || c.typ == "acTL"
+	}
+	if !animated {
+		return width, height, nil, nil
+	}
+
+	// chunks like PLTE and tRNS apply to all frames
+	var shared []pngChunk
+	for _, c := range chunks[1:] {
+		if c.typ == "IDAT" || c.typ == "fcTL" {
+			break
+		}
+		if c.typ != "acTL" {
+			shared = append(shared, c)
+		}
+	}
+
+	var frames []animationFrame
+	var control []byte
+	var frameData [][]byte
+	flush := func() error {
+		if control == nil {
+			return nil
+		}
+		frame, err := apngFrame(ihdr, shared, control, frameData)
+		if err != nil {
+			return err
+		}
+		frames = append(frames, frame)
+		control, frameData = nil, nil
+		return nil
+	}
+
+	for _, c := range chunks[1:] {
+		switch c.typ {
+		case "fcTL":
+			if err := flush(); err != nil {
+				return 0, 0, nil, err
+			}
+			if len(c.data) != 26 {
+				return 0, 0, nil, fmt.Errorf("invalid fcTL chunk")
+			}
+			control = c.data
+		case "IDAT":
+			// the default image is part of the animation only if preceded by fcTL
+			if control != nil {
+				frameData = append(frameData, c.data)
+			}
+		case "fdAT":
+			if len(c.data) < 4 {
+				return 0, 0, nil, fmt.Errorf("invalid fdAT chunk")
+			}
+			frameData = append(frameData, c.data[4:])
+		}
+	}
+	if err := flush(); err != nil {
+		return 0, 0, nil, err
+	}
+	return width, height, frames, nil
+}
+
+// apngFrame decodes the frame given by its fcTL chunk data and image data
+// by assembling a standalone PNG file
+func apngFrame(ihdr []byte, shared []pngChunk, control []byte, frameData [][]byte) (animationFrame, error) {
+	w := binary.BigEndian.Uint32(control[4:8])
+	h := binary.BigEndian.Uint32(control[8:12])
+	x := int(binary.BigEndian.Uint32(control[12:16]))
+	y := int(binary.BigEndian.Uint32(control[16:20]))
+	delayNum := binary.BigEndian.Uint16(control[20:22])
+	delayDen := binary.BigEndian.Uint16(control[22:24])
+	if delayDen == 0 {
+		delayDen = 100
+	}
+
+	frameIHDR := append([]byte{}, ihdr...)
+	binary.BigEndian.PutUint32(frameIHDR[0:4], w)
+	binary.BigEndian.PutUint32(frameIHDR[4:8], h)
+
+	var buf bytes.Buffer
+	buf.Write(pngSignature)
+	writePNGChunk(&buf, "IHDR", frameIHDR)
+	for _, c := range shared {
+		writePNGChunk(&buf, c.typ, c.data)
+	}
+	writePNGChunk(&buf, "IDAT", bytes.Join(frameData, nil))
+	writePNGChunk(&buf, "IEND", nil)
+
+	img, err := png.Decode(&buf)
+	if err != nil {
+		return animationFrame{}, err
+	}
+
+	frame := animationFrame{
+		img:   img,
+		rect:  image.Rect(x, y, x+int(w), y+int(h)),
+		blend: draw.Src,
+		delay: time.Duration(delayNum) * time.Second / time.Duration(delayDen),
+	}
+	switch control[24] {
+	case 1:
+		frame.dispose = disposeBackground
+	case 2:
+		frame.dispose = disposePrevious
+	}
+	if control[25] == 1 {
+		frame.blend = draw.Over
+	}
+	return frame, nil
+}
+
+// writePNGChunk writes a PNG chunk including length and CRC
+func writePNGChunk(buf *bytes.Buffer, typ string, data []byte) {
+	binary.Write(buf, binary.BigEndian, uint32(len(data)))
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(typ))
+	crc.Write(data)
+	buf.WriteString(typ)
+	buf.Write(data)
+	binary.Write(buf, binary.BigEndian, crc.Sum32())
+}
@@ -0,0 +1,233 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var (
+	red   = color.RGBA{0xFF, 0, 0, 0xFF}
+	green = color.RGBA{0, 0xFF, 0, 0xFF}
+	blue  = color.RGBA{0, 0, 0xFF, 0xFF}
+)
+
+// uniform returns an 8×8 image of the given color
+func uniform(c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	draw.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+	return img
+}
+
+// writeGIF stores the images as frames of an animated GIF in dir and returns its filepath
+func writeGIF(t *testing.T, dir string, frames ...image.Image) string {
+	g := &gif.GIF{}
+	for _, f := range frames {
+		p := image.NewPaletted(f.Bounds(), palette.Plan9)
+		draw.Draw(p, p.Bounds(), f, f.Bounds().Min, draw.Src)
+		g.Image = append(g.Image, p)
+		g.Delay = append(g.Delay, 10)
+	}
+	fp := filepath.Join(dir, "animation.gif")
+	fd, err := os.Create(fp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	if err := gif.EncodeAll(fd, g); err != nil {
+		t.Fatal(err)
+	}
+	return fp
+}
+
+// writeAPNG stores the opaque images as frames of an APNG in dir and returns its filepath.
+// The first frame defines the canvas; following frames are placed at their bounds
+func writeAPNG(t *testing.T, dir string, frames ...image.Image) string {
+	var out bytes.Buffer
+	out.Write(pngSignature)
+
+	seq := uint32(0)
+	for k, f := range frames {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, f); err != nil {
+			t.Fatal(err)
+		}
+
+		var idat []byte
+		data := buf.Bytes()[len(pngSignature):]
+		for len(data) >= 12 {
+			length := binary.BigEndian.Uint32(data[0:4])
+			typ, body := string(data[4:8]), data[8:8+length]
+			if typ == "IHDR" && k == 0 {
+				writePNGChunk(&out, "IHDR", body)
+				writePNGChunk(&out, "acTL", []byte{0, 0, 0, byte(len(frames)), 0, 0, 0, 0})
+			} else if typ == "IDAT" {
+				idat = append(idat, body...)
+			}
+			data = data[12+length:]
+		}
+
+		fctl := make([]byte, 26)
+		binary.BigEndian.PutUint32(fctl[0:4], seq)
+		binary.BigEndian.PutUint32(fctl[4:8], uint32(f.Bounds().Dx()))
+		binary.BigEndian.PutUint32(fctl[8:12], uint32(f.Bounds().Dy()))
+		binary.BigEndian.PutUint32(fctl[12:16], uint32(f.Bounds().Min.X))
+		binary.BigEndian.PutUint32(fctl[16:20], uint32(f.Bounds().Min.Y))
+		binary.BigEndian.PutUint16(fctl[20:22], 1)
+		binary.BigEndian.PutUint16(fctl[22:24], 10)
+		writePNGChunk(&out, "fcTL", fctl)
+		seq++
+
+		if k == 0 {
+			writePNGChunk(&out, "IDAT", idat)
+		} else {
+			fdat := make([]byte, 4, 4+len(idat))
+			binary.BigEndian.PutUint32(fdat, seq)
+			writePNGChunk(&out, "fdAT", append(fdat, idat...))
+			seq++
+		}
+	}
+	writePNGChunk(&out, "IEND", nil)
+
+	fp := filepath.Join(dir, "animation.png")
+	if err := os.WriteFile(fp, out.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return fp
+}
+
+// checkFrames ensures that the frames of img show the expected colors at (0, 0) and (3, 3)
+func checkFrames(t *testing.T, img *TaggedImage, expected [][2]color.RGBA) {
+	if len(img.Frames) != len(expected) {
+		t.Fatalf("%s: expected %d frames; got %d", img.Source, len(expected), len(img.Frames))
+	}
+	for k, f := range img.Frames {
+		if f.Width != 8 || f.Height != 8 {
+			t.Fatalf("%s: frame %d must have the dimensions of the canvas; got %d×%d", img.Source, k, f.Width, f.Height)
+		}
+		for p, pt := range []image.Point{{0, 0}, {3, 3}} {
+			if c := color.RGBAModel.Convert(f.Image.At(pt.X, pt.Y)); c != expected[k][p] {
+				t.Fatalf("%s: expected %v at %v of frame %d; got %v", img.Source, expected[k][p], pt, k, c)
+			}
+		}
+	}
+}
+
+func TestAnimatedGIF(t *testing.T) {
+	var img TaggedImage
+	fp := writeGIF(t, t.TempDir(), uniform(red), uniform(green), uniform(blue))
+	if err := img.FromFilepath(fp); err != nil {
+		t.Fatal(err)
+	}
+	if img.Frames != nil {
+		t.Fatal("frames must not be decoded unless requested")
+	}
+	if err := img.DecodeFrames(); err != nil {
+		t.Fatal(err)
+	}
+	checkFrames(t, &img, [][2]color.RGBA{{red, red}, {green, green}, {blue, blue}})
+	if img.Frames[1].Delay.Milliseconds() != 100 {
+		t.Fatalf("expected delay of 100ms; got %s", img.Frames[1].Delay)
+	}
+
+	c := defaultConfig()
+	c.Frames = true
+	if err := c.LoadImage(&img, fp); err != nil {
+		t.Fatal(err)
+	}
+	checkFrames(t, &img, [][2]color.RGBA{{red, red}, {green, green}, {blue, blue}})
+
+	// without frames, the encoded data is not kept
+	c.Frames = false
+	if err := c.LoadImage(&img, fp); err != nil {
+		t.Fatal(err)
+	}
+	if img.encoded != nil || img.Frames != nil {
+		t.Fatal("encoded data must be discarded unless frames are requested")
+	}
+}
+
+func TestAPNG(t *testing.T) {
+	patch := uniform(blue).SubImage(image.Rect(2, 2, 6, 6))
+	var img TaggedImage
+	if err := img.FromFilepath(writeAPNG(t, t.TempDir(), uniform(red), uniform(green), patch)); err != nil {
+		t.Fatal(err)
+	}
+	if err := img.DecodeFrames(); err != nil {
+		t.Fatal(err)
+	}
+	if img.Format != "png" {
+		t.Fatalf("expected format png; got %s", img.Format)
+	}
+	checkFrames(t, &img, [][2]color.RGBA{{red, red}, {green, green}, {green, blue}})
+
+	// still PNGs have no frames
+	if err := img.FromFilepath(FILES["black"]); err != nil {
+		t.Fatal(err)
+	}
+	if err := img.DecodeFrames(); err != nil {
+		t.Fatal(err)
+	}
+	if img.Frames != nil {
+		t.Fatalf("still image must not have frames; got %d", len(img.Frames))
+	}
+}
+
+// animation returns a TaggedImage with the given frames
+func animation(frames ...image.Image) TaggedImage {
+	var img TaggedImage
+	img.FromImage(frames[0], "animation")
+	for _, f := range frames {
+		var frame TaggedImage
+		frame.FromImage(f, "frame")
+		img.Frames = append(img.Frames, frame)
+	}
+	return img
+}
+
+func TestCompareFrames(t *testing.T) {
+	// reference sequence captured with twice the frame rate
+	c := defaultConfig()
+	c.BaseImg = animation(uniform(red), uniform(green), uniform(blue))
+	c.RefImg = animation(uniform(red), uniform(red), uniform(green), uniform(green), uniform(blue), uniform(blue))
+	var r Result
+	if err := CompareFrames(&c, &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Score != 0 || !r.Match {
+		t.Fatalf("aligned sequences must match; got score %f", r.Score)
+	}
+	if len(r.FrameScores) != 3 || len(r.FrameScores[0]) != 6 {
+		t.Fatalf("expected 3×6 frame scores; got %v", r.FrameScores)
+	}
+	expected := []FrameResult{{0, 0, 0, true}, {0, 1, 0, true}, {1, 2, 0, true}, {1, 3, 0, true}, {2, 4, 0, true}, {2, 5, 0, true}}
+	if len(r.Frames) != len(expected) {
+		t.Fatalf("expected alignment %v; got %v", expected, r.Frames)
+	}
+	for k := range expected {
+		if r.Frames[k] != expected[k] {
+			t.Fatalf("expected alignment %v; got %v", expected, r.Frames)
+		}
+	}
+
+	// still reference image
+	c.RefImg = TaggedImage{}
+	c.RefImg.FromImage(uniform(green), "green")
+	if err := CompareFrames(&c, &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.BestFrame.BaseFrame != 1 || r.BestFrame.RefFrame != 0 || r.BestFrame.Score != 0 {
+		t.Fatalf("green frame must be the best frame; got %v", r.BestFrame)
+	}
+	if r.Match || len(r.Frames) != 3 || r.Score <= 0 {
+		t.Fatalf("red and blue frames must not match; got %v", r.Frames)
+	}
+}
@@ -0,0 +1,97 @@
+package v1
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// ARCHIVE_MEMBER_SEPARATOR separates the filepath of an archive and the path
+// of a member inside the archive (e.g. 'refs.tar.gz!/grub/menu.png')
+const ARCHIVE_MEMBER_SEPARATOR = `!/`
+
+// ArchiveFormats lists the filename extensions of supported archives
+var ArchiveFormats = []string{".zip", ".tar", ".tar.gz", ".tgz"}
+
+// splitArchiveLocation splits a location 'archive!/member' if archive has an extension of ArchiveFormats
+func splitArchiveLocation(location string) (string, string, bool) {
+	idx := strings.Index(location, ARCHIVE_MEMBER_SEPARATOR)
+	for idx >= 0 {
+		archive := location[:idx]
+		for _, ext := range ArchiveFormats {
+			if strings.HasSuffix(strings.ToLower(archive), ext) {
+				return archive, location[idx+len(ARCHIVE_MEMBER_SEPARATOR):], true
+			}
+		}
+		next := strings.Index(location[idx+1:], ARCHIVE_MEMBER_SEPARATOR)
+		if next < 0 {
+			break
+		}
+		idx += 1 + next
+	}
+	return "", "", false
+}
+
+// FromArchive reads the image stored as member of the zip or (gzip-compressed) tar archive
+// at the given filepath and fills TaggedImage with its data. Member paths are relative
+// to the archive root; a leading slash or './' is ignored
+func (i *TaggedImage) FromArchive(archive, member string) error {
+	source := archive + ARCHIVE_MEMBER_SEPARATOR + member
+	member = path.Clean(strings.TrimPrefix(member, "/"))
+
+	if strings.HasSuffix(strings.ToLower(archive), ".zip") {
+		zr, err := zip.OpenReader(archive)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		for _, f := range zr.File {
+			if path.Clean(f.Name) != member || f.FileInfo().IsDir() {
+				continue
+			}
+			r, err := f.Open()
+			if err != nil {
+				return err
+			}
+			defer r.Close()
+			return i.FromReader(r, source)
+		}
+		return fmt.Errorf("member '%s' not found in archive '%s'", member, archive)
+	}
+
+	fd, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	// tar archives might be gzip-compressed independent of their extension
+	var r io.Reader = bufio.NewReader(fd)
+	if magic, err := r.(*bufio.Reader).Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gr.Close()
+		r = gr
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return fmt.Errorf("member '%s' not found in archive '%s'", member, archive)
+		} else if err != nil {
+			return fmt.Errorf("cannot read archive '%s': %s", archive, err)
+		}
+		if path.Clean(hdr.Name) == member && hdr.Typeflag == tar.TypeReg {
+			return i.FromReader(tr, source)
+		}
+	}
+}
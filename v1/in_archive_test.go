@@ -0,0 +1,126 @@
+package v1
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeArchive stores the given test images as members in a zip or tar archive
+// (gzip-compressed if compress is set) and returns its filepath
+func writeArchive(t *testing.T, name string, compress bool, members map[string]string) string {
+	fp := filepath.Join(t.TempDir(), name)
+	fd, err := os.Create(fp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+
+	var w io.Writer = fd
+	if compress {
+		gw := gzip.NewWriter(fd)
+		defer gw.Close()
+		w = gw
+	}
+
+	var zw *zip.Writer
+	var tw *tar.Writer
+	if filepath.Ext(name) == ".zip" {
+		zw = zip.NewWriter(w)
+		defer zw.Close()
+	} else {
+		tw = tar.NewWriter(w)
+		defer tw.Close()
+	}
+
+	for member, file := range members {
+		data, err := os.ReadFile(FILES[file])
+		if err != nil {
+			t.Fatal(err)
+		}
+		var mw io.Writer
+		if zw != nil {
+			if mw, err = zw.Create(member); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			if err := tw.WriteHeader(&tar.Header{Name: member, Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+				t.Fatal(err)
+			}
+			mw = tw
+		}
+		if _, err := mw.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return fp
+}
+
+func TestArchiveMembers(t *testing.T) {
+	members := map[string]string{"grub/menu.png": "grmlf_bo_back", "boot.png": "grmlf_bs_23"}
+	archives := []string{
+		writeArchive(t, "refs.zip", false, members),
+		writeArchive(t, "refs.tar", false, members),
+		writeArchive(t, "refs.tar.gz", true, members),
+		writeArchive(t, "refs.tgz", true, members),
+	}
+
+	for _, archive := range archives {
+		for member, file := range members {
+			var img, expected TaggedImage
+			if err := img.FromLocation(archive + "!/" + member); err != nil {
+				t.Fatal(err)
+			}
+			if err := expected.FromFilepath(FILES[file]); err != nil {
+				t.Fatal(err)
+			}
+			if img.Source != archive+"!/"+member || img.Format != "png" {
+				t.Fatalf("unexpected image %s", img.String())
+			}
+
+			c := Config{ColorSpace: "RGB", BaseImg: img, RefImg: expected}
+			var r Result
+			if err := Compare(&c, &r); err != nil {
+				t.Fatal(err)
+			}
+			if r.Score != 0 {
+				t.Fatalf("%s!/%s must equal %s; got score %f", archive, member, FILES[file], r.Score)
+			}
+		}
+
+		var img TaggedImage
+		if err := img.FromLocation(archive + "!/missing.png"); err == nil {
+			t.Fatalf("%s: missing member must be reported", archive)
+		}
+	}
+}
+
+func TestArchiveLocations(t *testing.T) {
+	test := func(location, archive, member string, ok bool) {
+		a, m, isArchive := splitArchiveLocation(location)
+		if a != archive || m != member || isArchive != ok {
+			t.Fatalf("'%s' must be split into '%s' and '%s' (%t); got '%s' and '%s' (%t)", location, archive, member, ok, a, m, isArchive)
+		}
+	}
+	test("refs.tar.gz!/grub/menu.png", "refs.tar.gz", "grub/menu.png", true)
+	test("refs.zip!/boot.png", "refs.zip", "boot.png", true)
+	test("dir!/refs.TGZ!/boot.png", "dir!/refs.TGZ", "boot.png", true)
+	test("images!/boot.png", "", "", false)
+	test("boot.png", "", "", false)
+}
+
+func TestArchiveMembersInConfig(t *testing.T) {
+	archive := writeArchive(t, "refs.tar.gz", true, map[string]string{"kB.png": "grml_kB", "MB.png": "grml_MB"})
+
+	c := defaultConfig()
+	if _, err := c.FromArgs([]string{"screenshot-compare", archive + "!/kB.png", archive + "!/MB.png"}, "", 2); err != nil {
+		t.Fatal(err)
+	}
+	if c.BaseImg.Source != archive+"!/kB.png" || c.RefImg.Source != archive+"!/MB.png" {
+		t.Fatalf("images must be read from %s; got %s", archive, c.String())
+	}
+}
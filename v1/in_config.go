@@ -2,6 +2,7 @@ package v1
 
 import (
 	"fmt"
+	"image/color"
 	"os"
 	"time"
 )
@@ -10,13 +11,21 @@ import (
 // Two runs of the executable with the same Config must yield the same result.
 type Config struct {
 	// ColorSpace to use for comparison.
-	// Currently supported: {Y'UV, RGB}
+	// Currently supported: {Y'UV, RGB, Gray, R, G, B, Y}.
+	// Gray compares luma only, R, G, B and Y (of Y'UV) compare a single channel
 	ColorSpace string
+	// Luma defines the weights to compute luma for color space Gray.
+	// Currently supported: {BT.601, BT.709}. Empty means BT.601
+	Luma string
 	// Timeout defines a duration threshold. Timeout does not consider PreWait time.
 	// If comparison exceeds this duration threshold, it will terminate prematurely.
 	Timeout time.Duration
 	// PreWait defines how long to wait before comparison starts
 	PreWait time.Duration
+	// Interval defines the duration between two attempts of WaitForMatch. Zero means DEFAULT_INTERVAL
+	Interval time.Duration
+	// Deadline defines the maximum duration of WaitForMatch. Zero means no deadline
+	Deadline time.Duration
 	// AdmissibleDiffPixel is a fixed number N of pixels that are
 	// allowed to be different. The comparison score will ignore the
 	// the first N pixels yielding _any_ difference
@@ -24,6 +33,36 @@ type Config struct {
 	// NoDimensionError returns the maximum difference value as Score if
 	// dimensions do not match instead of returning an error
 	NoDimensionError bool
+	// Linear defines whether gamma-encoded sRGB values are converted to linear light
+	// before color space conversion and distance computation
+	Linear bool
+	// Frames defines whether all frames of animated images (GIF, APNG) are compared
+	// by CompareFrames instead of the first frame only
+	Frames bool
+	// Orient defines whether images are rotated and flipped according to their EXIF orientation
+	Orient bool
+	// ICC defines whether images with an embedded Display P3 or Adobe RGB color profile
+	// are converted to sRGB
+	ICC bool
+	// Raw defines the memory layout of images given as raw framebuffer dumps
+	// (locations with prefix RAW_LOCATION_PREFIX)
+	Raw RawFormat
+	// Threshold is the maximum Score for the images to be considered matching.
+	// Nil means DEFAULT_THRESHOLD; zero only accepts images without any difference
+	Threshold *float64
+	// AlphaMode defines how alpha channels are considered.
+	// Currently supported: {ref-mask, both-mask, composite-over-background, compare-alpha-as-channel}.
+	// Empty means ref-mask (only the alpha channel of RefImg weights the pixels)
+	AlphaMode string
+	// Background is the opaque color both images are flattened over in AlphaMode
+	// composite-over-background. Nil means DEFAULT_BACKGROUND (white)
+	Background color.Color
+	// Metrics lists metrics to evaluate in one comparison run.
+	// If empty, only the pixel-by-pixel score in ColorSpace is computed
+	Metrics []MetricSpec
+	// Pending is the directory failed comparisons are recorded in for approval (see RecordPending).
+	// Empty means failed comparisons are not recorded
+	Pending string
 	// BaseImg is the image to compare in memory
 	BaseImg TaggedImage
 	// RefImg is the image to compare with ("expected image").
@@ -31,10 +70,39 @@ type Config struct {
 	RefImg TaggedImage
 }
 
-func (c *Config) Valid() error {
-	if c.ColorSpace != "Y'UV" && c.ColorSpace != "RGB" {
+// ValidOptions checks the comparison options of Config without its images.
+// Commands reading their images themselves use it to reject invalid options early
+func (c *Config) ValidOptions() error {
+	if !isColorSpace(c.ColorSpace) {
 		return fmt.Errorf(`color space is invalid`)
 	}
+	if !isLumaStandard(c.Luma) {
+		return fmt.Errorf(`luma standard is invalid`)
+	}
+	if !validThreshold(c.Threshold) {
+		return fmt.Errorf(`threshold must be between 0 and 1`)
+	}
+	if !isAlphaMode(c.AlphaMode) {
+		return fmt.Errorf(`alpha mode is invalid`)
+	}
+	if _, _, _, a := c.background().RGBA(); c.AlphaMode == "composite-over-background" && a != 0xFFFF {
+		return fmt.Errorf(`background color must be opaque to composite over it; got %s`, colorToHex(c.background()))
+	}
+	if c.Interval < 0 || c.Deadline < 0 {
+		return fmt.Errorf(`interval and deadline must not be negative`)
+	}
+	for _, m := range c.Metrics {
+		if err := m.Valid(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Config) Valid() error {
+	if err := c.ValidOptions(); err != nil {
+		return err
+	}
 	if c.BaseImg.Image == nil {
 		return fmt.Errorf(`base image required`)
 	}
@@ -72,10 +140,11 @@ func (c *Config) Valid() error {
 		fmt.Fprintf(os.Stderr, "warning: admissible diff pixel > reference-image(width * height)\n")
 	}
 
-	actualBWidth := c.BaseImg.Image.Bounds().Max.X - c.BaseImg.Image.Bounds().Min.X
-	actualBHeight := c.BaseImg.Image.Bounds().Max.Y - c.BaseImg.Image.Bounds().Min.Y
-	actualRWidth := c.RefImg.Image.Bounds().Max.X - c.RefImg.Image.Bounds().Min.X
-	actualRHeight := c.RefImg.Image.Bounds().Max.Y - c.RefImg.Image.Bounds().Min.Y
+	// MinX and MinY are absolute coordinates, hence compare with the maximum bounds
+	actualBWidth := c.BaseImg.Image.Bounds().Max.X
+	actualBHeight := c.BaseImg.Image.Bounds().Max.Y
+	actualRWidth := c.RefImg.Image.Bounds().Max.X
+	actualRHeight := c.RefImg.Image.Bounds().Max.Y
 	if c.BaseImg.MinX+c.BaseImg.Width > actualBWidth {
 		return fmt.Errorf(`width of base image is smaller than MinX + comparison Width`)
 	}
@@ -93,6 +162,19 @@ func (c *Config) Valid() error {
 }
 
 func (c *Config) String() string {
-	return fmt.Sprintf(`{colors: %v, timeout: %s, wait: %s, diffpixel: %d, nodimerr: %t, baseimg: %s, refimg: %s}`,
-		c.ColorSpace, c.Timeout, c.PreWait, c.AdmissibleDiffPixel, c.NoDimensionError, c.BaseImg.String(), c.RefImg.String())
+	return fmt.Sprintf(`{colors: %v, luma: %s, timeout: %s, wait: %s, interval: %s, deadline: %s, diffpixel: %d, nodimerr: %t, linear: %t, frames: %t, orient: %t, icc: %t, raw: %s, threshold: %g, alpha: %s, background: %s, metrics: %v, pending: %s, baseimg: %s, refimg: %s}`,
+		c.ColorSpace, c.Luma, c.Timeout, c.PreWait, c.Interval, c.Deadline, c.AdmissibleDiffPixel, c.NoDimensionError, c.Linear, c.Frames, c.Orient, c.ICC, c.Raw, c.threshold(), c.AlphaMode, colorToHex(c.background()), c.Metrics, c.Pending, c.BaseImg.String(), c.RefImg.String())
+}
+
+// threshold returns Threshold or DEFAULT_THRESHOLD if Threshold is nil
+func (c *Config) threshold() float64 {
+	return thresholdOrDefault(c.Threshold)
+}
+
+// metricColorSpace returns the color space to use for metric m
+func (c *Config) metricColorSpace(m MetricSpec) string {
+	if m.ColorSpace == "" {
+		return c.ColorSpace
+	}
+	return m.ColorSpace
 }
@@ -0,0 +1,124 @@
+package v1
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// LocationLoader reads the image at the given location URI and fills TaggedImage with its data
+type LocationLoader func(i *TaggedImage, location *url.URL) error
+
+var (
+	locationLoadersMutex sync.RWMutex
+	locationLoaders      = make(map[string]LocationLoader)
+)
+
+// RegisterScheme registers a LocationLoader for locations with the given URI scheme
+// (e.g. "vnc" for 'vnc://host:5900'). Like image.RegisterFormat, it is typically
+// called in the init function of the package providing the image source.
+func RegisterScheme(scheme string, loader LocationLoader) {
+	locationLoadersMutex.Lock()
+	defer locationLoadersMutex.Unlock()
+	locationLoaders[strings.ToLower(scheme)] = loader
+}
+
+// Schemes returns the URI schemes of all registered LocationLoaders in lexicographic order
+func Schemes() []string {
+	locationLoadersMutex.RLock()
+	defer locationLoadersMutex.RUnlock()
+	schemes := make([]string, 0, len(locationLoaders))
+	for scheme := range locationLoaders {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// FromLocation reads an image from the given location and fills TaggedImage with its data.
+// A location is a filepath, a member of an archive like 'refs.tar.gz!/grub/menu.png',
+// "-" for standard input or a URI like 'scheme://…' with a scheme registered by RegisterScheme
+func (i *TaggedImage) FromLocation(location string) error {
+	if err := i.fromLocation(location); err != nil {
+		return err
+	}
+	i.location = location
+	return nil
+}
+
+// LoadImage reads the image at the given location into i.
+// Locations with prefix RAW_LOCATION_PREFIX are read as raw framebuffer dumps using Config.Raw.
+// Any other location is read with TaggedImage.FromLocation.
+// Frames of animated images are decoded if Config.Frames is set and cannot be decoded later otherwise.
+// EXIF orientation and ICC profiles are applied if enabled by Config.Orient and Config.ICC
+func (c *Config) LoadImage(i *TaggedImage, location string) error {
+	if location == stdinPlaceholder {
+		location = "-"
+	}
+	if err := c.readImage(i, location); err != nil {
+		return err
+	}
+	i.location = location
+	if c.Frames {
+		if err := i.DecodeFrames(); err != nil {
+			return err
+		}
+	} else {
+		// the encoded data is only kept for DecodeFrames
+		i.encoded = nil
+	}
+	if c.Orient {
+		i.ApplyOrientation()
+	}
+	if c.ICC {
+		i.ConvertToSRGB()
+	}
+	return nil
+}
+
+// readImage reads the image at the given location into i without applying any transformations
+func (c *Config) readImage(i *TaggedImage, location string) error {
+	if !strings.HasPrefix(location, RAW_LOCATION_PREFIX) {
+		return i.FromLocation(location)
+	}
+
+	fp := strings.TrimPrefix(location, RAW_LOCATION_PREFIX)
+	if fp == "-" {
+		return i.FromRawFramebuffer(os.Stdin, c.Raw, location)
+	}
+	reader, err := os.Open(fp)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	return i.FromRawFramebuffer(reader, c.Raw, location)
+}
+
+// fromLocation implements FromLocation
+func (i *TaggedImage) fromLocation(location string) error {
+	if location == "-" {
+		return i.FromReader(os.Stdin, `<stdin>`)
+	}
+
+	if strings.Contains(location, "://") {
+		u, err := url.Parse(location)
+		if err != nil {
+			return err
+		}
+		locationLoadersMutex.RLock()
+		loader, ok := locationLoaders[strings.ToLower(u.Scheme)]
+		locationLoadersMutex.RUnlock()
+		if !ok {
+			return fmt.Errorf("unsupported location scheme '%s'; supported schemes: %s", u.Scheme, strings.Join(Schemes(), ", "))
+		}
+		return loader(i, u)
+	}
+
+	if archive, member, ok := splitArchiveLocation(location); ok {
+		return i.FromArchive(archive, member)
+	}
+	return i.FromFilepath(location)
+}
@@ -0,0 +1,106 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+)
+
+// BatchComparison is a named comparison of a Manifest
+type BatchComparison struct {
+	// Name identifies the comparison in the results
+	Name string
+	// Config holds the options of this comparison. Its images are not loaded;
+	// RunBatch reads them from BaseImg and RefImg
+	Config Config
+	// BaseImg is the location of the base image
+	BaseImg string
+	// RefImg is the location of the reference image
+	RefImg string
+	// Mask is the location of an image whose alpha channel is applied to the reference image
+	// (see TaggedImage.ApplyMask). Empty means no mask
+	Mask string
+}
+
+// Manifest lists many named comparisons to run with RunBatch
+type Manifest struct {
+	// Comparisons lists the comparisons in the order of the manifest file
+	Comparisons []BatchComparison
+}
+
+// FromFile reads a manifest from a JSON file. Its "defaults" object and every object
+// of its "comparisons" array accept the keys of the JSON configuration file. Comparisons
+// additionally accept "name" and "mask". Each comparison starts with the options of
+// the given Config, overridden by the non-zero defaults and then its own non-zero values.
+// Relative filepaths of images are relative to the directory of the manifest file. Example:
+//
+//	{
+//	  "defaults": {"colors": "Y'UV", "threshold": 0.05},
+//	  "comparisons": [
+//	    {"name": "grub", "baseimg": "shots/grub.png", "refimg": "refs/grub.png", "diffpixel": 10},
+//	    {"name": "login", "baseimg": "shots/login.png", "refimg": "refs/login.png", "mask": "masks/login.png"}
+//	  ]
+//	}
+func (m *Manifest) FromFile(fp string, defaults *Config) error {
+	type jsonManifest struct {
+		Defaults    json.RawMessage   `json:"defaults,omitempty"`
+		Comparisons []json.RawMessage `json:"comparisons"`
+	}
+	type jsonComparison struct {
+		Name string `json:"name"`
+		Mask string `json:"mask,omitempty"`
+	}
+
+	var manifest jsonManifest
+	data, err := ioutil.ReadFile(fp)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return err
+	}
+	if len(manifest.Comparisons) == 0 {
+		return fmt.Errorf(`manifest '%s' declares no comparisons`, fp)
+	}
+
+	base := *defaults
+	base.BaseImg, base.RefImg = TaggedImage{}, TaggedImage{}
+	if len(manifest.Defaults) > 0 {
+		if _, _, _, err := base.fromJSONData(manifest.Defaults, 3); err != nil {
+			return fmt.Errorf(`defaults: %s`, err)
+		}
+	}
+
+	dir := filepath.Dir(fp)
+	names := make(map[string]bool)
+	comparisons := make([]BatchComparison, 0, len(manifest.Comparisons))
+	for k, raw := range manifest.Comparisons {
+		var entry jsonComparison
+		if err := json.Unmarshal(raw, &entry); err != nil {
+			return err
+		}
+		if entry.Name == "" {
+			entry.Name = fmt.Sprintf("comparison %d", k+1)
+		}
+		if names[entry.Name] {
+			return fmt.Errorf(`comparison name '%s' is not unique`, entry.Name)
+		}
+		names[entry.Name] = true
+
+		comparison := BatchComparison{Name: entry.Name, Config: base, Mask: resolveLocation(dir, entry.Mask)}
+		baseImg, refImg, _, err := comparison.Config.fromJSONData(raw, 3)
+		if err != nil {
+			return fmt.Errorf(`comparison '%s': %s`, entry.Name, err)
+		}
+		if baseImg == "" || refImg == "" {
+			return fmt.Errorf(`comparison '%s': missing JSON parameter baseimg or refimg`, entry.Name)
+		}
+		comparison.BaseImg = resolveLocation(dir, baseImg)
+		comparison.RefImg = resolveLocation(dir, refImg)
+		comparisons = append(comparisons, comparison)
+	}
+
+	m.Comparisons = comparisons
+	return nil
+}
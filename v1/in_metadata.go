@@ -0,0 +1,179 @@
+package v1
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io"
+	"unicode/utf16"
+)
+
+// jpegSOI is the marker at the beginning of every JPEG file
+var jpegSOI = []byte{0xFF, 0xD8}
+
+// imageMetadata contains the metadata of an image file relevant for comparison
+type imageMetadata struct {
+	// orientation is the EXIF orientation (1 to 8). Zero means unknown
+	orientation int
+	// profile is the description of the embedded ICC profile. Empty means none
+	profile string
+}
+
+// readMetadata extracts EXIF orientation and ICC profile description from JPEG and PNG files.
+// Malformed metadata is ignored
+func readMetadata(data []byte) imageMetadata {
+	var exif, icc []byte
+	switch {
+	case bytes.HasPrefix(data, jpegSOI):
+		exif, icc = jpegMetadata(data)
+	case bytes.HasPrefix(data, pngSignature):
+		exif, icc = pngMetadata(data)
+	}
+	return imageMetadata{orientation: exifOrientation(exif), profile: iccDescription(icc)}
+}
+
+// jpegMetadata returns the EXIF data of the APP1 segment and the ICC profile
+// assembled from the APP2 segments of a JPEG file
+func jpegMetadata(data []byte) ([]byte, []byte) {
+	var exif []byte
+	iccChunks := make(map[byte][]byte)
+
+	for rest := data[2:]; len(rest) >= 4 && rest[0] == 0xFF; {
+		marker := rest[1]
+		// start of scan: no more metadata segments
+		if marker == 0xDA || marker == 0xD9 {
+			break
+		}
+		length := int(binary.BigEndian.Uint16(rest[2:4]))
+		if length < 2 || len(rest) < 2+length {
+			break
+		}
+		segment := rest[4 : 2+length]
+		switch {
+		case marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")):
+			exif = segment[6:]
+		case marker == 0xE2 && bytes.HasPrefix(segment, []byte("ICC_PROFILE\x00")) && len(segment) >= 14:
+			iccChunks[segment[12]] = segment[14:]
+		}
+		rest = rest[2+length:]
+	}
+
+	var icc []byte
+	for seq := byte(1); iccChunks[seq] != nil; seq++ {
+		icc = append(icc, iccChunks[seq]...)
+	}
+	return exif, icc
+}
+
+// pngMetadata returns the data of the eXIf chunk and the decompressed ICC profile of the iCCP chunk
+func pngMetadata(data []byte) ([]byte, []byte) {
+	var exif, icc []byte
+	for rest := data[len(pngSignature):]; len(rest) >= 12; {
+		length := int(binary.BigEndian.Uint32(rest[0:4]))
+		if length < 0 || len(rest) < 12+length {
+			break
+		}
+		typ, body := string(rest[4:8]), rest[8:8+length]
+		switch typ {
+		case "eXIf":
+			exif = body
+		case "iCCP":
+			// profile name, null separator, compression method, compressed profile
+			if sep := bytes.IndexByte(body, 0); sep >= 0 && len(body) > sep+2 {
+				if zr, err := zlib.NewReader(bytes.NewReader(body[sep+2:])); err == nil {
+					icc, _ = io.ReadAll(zr)
+				}
+			}
+		case "IDAT":
+			return exif, icc
+		}
+		rest = rest[12+length:]
+	}
+	return exif, icc
+}
+
+// exifOrientation reads tag 0x0112 (Orientation) of IFD0 of the given EXIF (TIFF) data
+func exifOrientation(exif []byte) int {
+	if len(exif) < 8 {
+		return 0
+	}
+	var order binary.ByteOrder
+	switch string(exif[0:4]) {
+	case "II*\x00":
+		order = binary.LittleEndian
+	case "MM\x00*":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+
+	ifd := int(order.Uint32(exif[4:8]))
+	if ifd < 8 || len(exif) < ifd+2 {
+		return 0
+	}
+	count := int(order.Uint16(exif[ifd : ifd+2]))
+	for e := 0; e < count; e++ {
+		entry := ifd + 2 + 12*e
+		if len(exif) < entry+12 {
+			return 0
+		}
+		if order.Uint16(exif[entry:entry+2]) == 0x0112 {
+			orientation := int(order.Uint16(exif[entry+8 : entry+10]))
+			if orientation < 1 || orientation > 8 {
+				return 0
+			}
+			return orientation
+		}
+	}
+	return 0
+}
+
+// iccDescription reads the profile description tag ('desc') of an ICC profile.
+// Both the ICC v2 type 'desc' and the ICC v4 type 'mluc' are supported
+func iccDescription(icc []byte) string {
+	if len(icc) < 132 {
+		return ""
+	}
+	count := int(binary.BigEndian.Uint32(icc[128:132]))
+	for t := 0; t < count; t++ {
+		entry := 132 + 12*t
+		if len(icc) < entry+12 {
+			return ""
+		}
+		if string(icc[entry:entry+4]) != "desc" {
+			continue
+		}
+		offset := int(binary.BigEndian.Uint32(icc[entry+4 : entry+8]))
+		size := int(binary.BigEndian.Uint32(icc[entry+8 : entry+12]))
+		if offset < 0 || size < 12 || len(icc) < offset+size {
+			return ""
+		}
+		tag := icc[offset : offset+size]
+
+		switch string(tag[0:4]) {
+		case "desc":
+			length := int(binary.BigEndian.Uint32(tag[8:12]))
+			if length < 1 || len(tag) < 12+length {
+				return ""
+			}
+			return string(bytes.TrimRight(tag[12:12+length], "\x00"))
+		case "mluc":
+			// use the first record
+			if len(tag) < 28 || binary.BigEndian.Uint32(tag[8:12]) == 0 {
+				return ""
+			}
+			length := int(binary.BigEndian.Uint32(tag[20:24]))
+			start := int(binary.BigEndian.Uint32(tag[24:28]))
+			if start < 0 || length < 0 || len(tag) < start+length {
+				return ""
+			}
+			units := make([]uint16, length/2)
+			for u := range units {
+				units[u] = binary.BigEndian.Uint16(tag[start+2*u:])
+			}
+			return string(utf16.Decode(units))
+		}
+		return ""
+	}
+	return ""
+}
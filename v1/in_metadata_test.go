@@ -0,0 +1,299 @@
+package v1
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"unicode/utf16"
+)
+
+// exifWithOrientation returns a little-endian TIFF structure with the given orientation in IFD0
+func exifWithOrientation(orientation uint16) []byte {
+	var b bytes.Buffer
+	b.WriteString("II*\x00")
+	binary.Write(&b, binary.LittleEndian, uint32(8))
+	binary.Write(&b, binary.LittleEndian, uint16(1))
+	binary.Write(&b, binary.LittleEndian, []uint16{0x0112, 3})
+	binary.Write(&b, binary.LittleEndian, uint32(1))
+	binary.Write(&b, binary.LittleEndian, []uint16{orientation, 0})
+	binary.Write(&b, binary.LittleEndian, uint32(0))
+	return b.Bytes()
+}
+
+// iccProfile returns a minimal ICC profile with the given description
+// stored as ICC v2 type 'desc' or ICC v4 type 'mluc'
+func iccProfile(description string, v4 bool) []byte {
+	var tag bytes.Buffer
+	if v4 {
+		units := utf16.Encode([]rune(description))
+		tag.WriteString("mluc\x00\x00\x00\x00")
+		binary.Write(&tag, binary.BigEndian, []uint32{1, 12})
+		tag.WriteString("enUS")
+		binary.Write(&tag, binary.BigEndian, []uint32{uint32(2 * len(units)), 28})
+		binary.Write(&tag, binary.BigEndian, units)
+	} else {
+		tag.WriteString("desc\x00\x00\x00\x00")
+		binary.Write(&tag, binary.BigEndian, uint32(len(description)+1))
+		tag.WriteString(description + "\x00")
+	}
+
+	profile := make([]byte, 128)
+	profile = binary.BigEndian.AppendUint32(profile, 1)
+	profile = append(profile, "desc"...)
+	profile = binary.BigEndian.AppendUint32(profile, 144)
+	profile = binary.BigEndian.AppendUint32(profile, uint32(tag.Len()))
+	profile = append(profile, tag.Bytes()...)
+	binary.BigEndian.PutUint32(profile[0:4], uint32(len(profile)))
+	return profile
+}
+
+// withJPEGSegment inserts an APPn segment directly after SOI of a JPEG file
+func withJPEGSegment(data []byte, marker byte, payload []byte) []byte {
+	segment := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(2+len(payload)))
+	out := append([]byte{}, data[:2]...)
+	out = append(out, segment...)
+	out = append(out, payload...)
+	return append(out, data[2:]...)
+}
+
+// withPNGChunk inserts a chunk directly after IHDR of a PNG file
+func withPNGChunk(data []byte, typ string, payload []byte) []byte {
+	var chunk bytes.Buffer
+	writePNGChunk(&chunk, typ, payload)
+	ihdrEnd := len(pngSignature) + 12 + 13
+	out := append([]byte{}, data[:ihdrEnd]...)
+	out = append(out, chunk.Bytes()...)
+	return append(out, data[ihdrEnd:]...)
+}
+
+func encodeTestImage(t *testing.T, img image.Image, asJPEG bool) []byte {
+	var buf bytes.Buffer
+	var err error
+	if asJPEG {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 100})
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestOrientations(t *testing.T) {
+	// 3×2 image with unique pixels
+	src := image.NewGray(image.Rect(0, 0, 3, 2))
+	copy(src.Pix, []uint8{1, 2, 3, 4, 5, 6})
+
+	// expected rows of the upright image for each EXIF orientation
+	expected := map[int][][]uint8{
+		1: {{1, 2, 3}, {4, 5, 6}},
+		2: {{3, 2, 1}, {6, 5, 4}},
+		3: {{6, 5, 4}, {3, 2, 1}},
+		4: {{4, 5, 6}, {1, 2, 3}},
+		5: {{1, 4}, {2, 5}, {3, 6}},
+		6: {{4, 1}, {5, 2}, {6, 3}},
+		7: {{6, 3}, {5, 2}, {4, 1}},
+		8: {{3, 6}, {2, 5}, {1, 4}},
+	}
+
+	for orientation, rows := range expected {
+		var img TaggedImage
+		img.FromImage(src, "gray")
+		img.Orientation = orientation
+		img.ApplyOrientation()
+		if img.Width != len(rows[0]) || img.Height != len(rows) {
+			t.Fatalf("orientation %d: unexpected dimensions %d×%d", orientation, img.Width, img.Height)
+		}
+		for y, row := range rows {
+			for x, v := range row {
+				if g := color.GrayModel.Convert(img.Image.At(x, y)).(color.Gray).Y; g != v {
+					t.Fatalf("orientation %d: expected %d at (%d, %d); got %d", orientation, v, x, y, g)
+				}
+			}
+		}
+		if (orientation == 1) != (len(img.Transforms) == 0) {
+			t.Fatalf("orientation %d: unexpected transforms %v", orientation, img.Transforms)
+		}
+	}
+}
+
+func TestTransformFrames(t *testing.T) {
+	wide := image.NewRGBA(image.Rect(0, 0, 8, 4))
+	draw.Draw(wide, wide.Bounds(), image.NewUniform(red), image.Point{}, draw.Src)
+	img := animation(wide, wide)
+	img.Orientation = 6
+	img.ColorProfile = "Display P3"
+
+	img.ApplyOrientation()
+	img.ConvertToSRGB()
+	for k, f := range append([]TaggedImage{img}, img.Frames...) {
+		if f.Width != 4 || f.Height != 8 || f.Image.Bounds().Dx() != 4 {
+			t.Fatalf("image %d must be rotated; got %s", k, f.String())
+		}
+		if len(f.Transforms) != 2 || f.Transforms[1] != "icc:Display P3->sRGB" {
+			t.Fatalf("image %d must be converted to sRGB; got %v", k, f.Transforms)
+		}
+	}
+}
+
+func TestEXIFOrientation(t *testing.T) {
+	// left half red, right half blue
+	src := image.NewRGBA(image.Rect(0, 0, 32, 16))
+	draw.Draw(src, image.Rect(0, 0, 16, 16), image.NewUniform(red), image.Point{}, draw.Src)
+	draw.Draw(src, image.Rect(16, 0, 32, 16), image.NewUniform(blue), image.Point{}, draw.Src)
+
+	payload := append([]byte("Exif\x00\x00"), exifWithOrientation(6)...)
+	files := map[string][]byte{
+		"jpeg": withJPEGSegment(encodeTestImage(t, src, true), 0xE1, payload),
+		"png":  withPNGChunk(encodeTestImage(t, src, false), "eXIf", exifWithOrientation(6)),
+	}
+
+	for format, data := range files {
+		var img TaggedImage
+		if err := img.FromBytes(data); err != nil {
+			t.Fatal(err)
+		}
+		if img.Orientation != 6 || img.Width != 32 {
+			t.Fatalf("%s: orientation must be read, but not applied; got %s", format, img.String())
+		}
+
+		img.ApplyOrientation()
+		if img.Width != 16 || img.Height != 32 || len(img.Transforms) != 1 || img.Transforms[0] != "orientation:rotate-90" {
+			t.Fatalf("%s: image must be rotated by 90° clockwise; got %s", format, img.String())
+		}
+		top, _, _, _ := img.Image.At(8, 4).RGBA()
+		_, _, bottom, _ := img.Image.At(8, 28).RGBA()
+		if top < 0xF000 || bottom < 0xF000 {
+			t.Fatalf("%s: red must be at the top and blue at the bottom", format)
+		}
+	}
+}
+
+func TestICCProfiles(t *testing.T) {
+	p3 := color.NRGBA{0xCC, 0x66, 0x33, 0xFF}
+	src := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	draw.Draw(src, src.Bounds(), image.NewUniform(p3), image.Point{}, draw.Src)
+
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write(iccProfile("Display P3", true))
+	zw.Close()
+	iccp := append([]byte("icc\x00\x00"), compressed.Bytes()...)
+	pngData := withPNGChunk(encodeTestImage(t, src, false), "iCCP", iccp)
+
+	var img TaggedImage
+	if err := img.FromBytes(pngData); err != nil {
+		t.Fatal(err)
+	}
+	if img.ColorProfile != "Display P3" {
+		t.Fatalf("expected ICC profile 'Display P3'; got '%s'", img.ColorProfile)
+	}
+
+	img.ConvertToSRGB()
+	if len(img.Transforms) != 1 || img.Transforms[0] != "icc:Display P3->sRGB" {
+		t.Fatalf("unexpected transforms %v", img.Transforms)
+	}
+
+	// reference conversion with the well-known Display P3 to sRGB matrix
+	m := [3][3]float64{{1.2249, -0.2247, 0}, {-0.0420, 1.0419, 0}, {-0.0197, -0.0786, 1.0979}}
+	lin := [3]float64{}
+	for k, v := range []uint8{p3.R, p3.G, p3.B} {
+		lin[k] = toLinear(float64(v)*257) / 65535
+	}
+	r, g, b, _ := img.Image.At(1, 1).RGBA()
+	for k, actual := range []uint32{r, g, b} {
+		v := m[k][0]*lin[0] + m[k][1]*lin[1] + m[k][2]*lin[2]
+		expected := srgbColorSpace.encode(v)
+		if math.Abs(float64(actual)-float64(expected)) > 0.002*65535 {
+			t.Fatalf("channel %d: expected %d; got %d", k, expected, actual)
+		}
+	}
+
+	// JPEG with ICC v2 profile split into two APP2 segments
+	profile := iccProfile("Adobe RGB (1998)", false)
+	jpegData := encodeTestImage(t, src, true)
+	jpegData = withJPEGSegment(jpegData, 0xE2, append([]byte("ICC_PROFILE\x00\x02\x02"), profile[100:]...))
+	jpegData = withJPEGSegment(jpegData, 0xE2, append([]byte("ICC_PROFILE\x00\x01\x02"), profile[:100]...))
+	if err := img.FromBytes(jpegData); err != nil {
+		t.Fatal(err)
+	}
+	if img.ColorProfile != "Adobe RGB (1998)" {
+		t.Fatalf("expected ICC profile 'Adobe RGB (1998)'; got '%s'", img.ColorProfile)
+	}
+	img.ConvertToSRGB()
+	if len(img.Transforms) != 1 {
+		t.Fatalf("Adobe RGB must be converted to sRGB; got %v", img.Transforms)
+	}
+
+	// images with sRGB or without profiles are kept
+	if err := img.FromBytes(withJPEGSegment(encodeTestImage(t, src, true), 0xE2, append([]byte("ICC_PROFILE\x00\x01\x01"), iccProfile("sRGB IEC61966-2.1", false)...))); err != nil {
+		t.Fatal(err)
+	}
+	img.ConvertToSRGB()
+	if len(img.Transforms) != 0 {
+		t.Fatalf("images without known profile must not be converted; got %v", img.Transforms)
+	}
+}
+
+func TestTransformsInConfig(t *testing.T) {
+	dir := t.TempDir()
+	src := image.NewRGBA(image.Rect(0, 0, 32, 16))
+	draw.Draw(src, src.Bounds(), image.NewUniform(green), image.Point{}, draw.Src)
+	rotated := filepath.Join(dir, "rotated.png")
+	if err := os.WriteFile(rotated, withPNGChunk(encodeTestImage(t, src, false), "eXIf", exifWithOrientation(8)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := defaultConfig()
+	if _, err := c.FromArgs([]string{"screenshot-compare", "--orient", "--icc", rotated, rotated}, "", 2); err != nil {
+		t.Fatal(err)
+	}
+	if !c.Orient || !c.ICC || c.BaseImg.Width != 16 || c.BaseImg.Height != 32 {
+		t.Fatalf("EXIF orientation must be applied; got %s", c.String())
+	}
+
+	c = defaultConfig()
+	if _, err := c.FromArgs([]string{"screenshot-compare", rotated, rotated}, "", 2); err != nil {
+		t.Fatal(err)
+	}
+	if c.BaseImg.Width != 32 || len(c.BaseImg.Transforms) != 0 {
+		t.Fatalf("EXIF orientation must not be applied by default; got %s", c.String())
+	}
+}
+
+func TestApplyMask(t *testing.T) {
+	var img, mask TaggedImage
+	img.FromImage(uniform(red), "red")
+	m := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	draw.Draw(m, image.Rect(0, 0, 4, 8), image.NewUniform(color.NRGBA{0, 0, 0, 0xFF}), image.Point{}, draw.Src)
+	mask.FromImage(m, "mask")
+
+	if err := img.ApplyMask(&mask); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, a := img.Image.At(1, 1).RGBA(); a != 0xFFFF {
+		t.Fatalf("opaque area of mask must be kept; got alpha %d", a)
+	}
+	if _, _, _, a := img.Image.At(6, 1).RGBA(); a != 0 {
+		t.Fatalf("transparent area of mask must be transparent; got alpha %d", a)
+	}
+	if len(img.Transforms) != 1 || img.Transforms[0] != "mask:mask" {
+		t.Fatalf("unexpected transforms %v", img.Transforms)
+	}
+
+	mask.FromImage(image.NewNRGBA(image.Rect(0, 0, 4, 4)), "small")
+	if err := img.ApplyMask(&mask); err == nil {
+		t.Fatal("mask with different dimensions must be rejected")
+	}
+}
@@ -0,0 +1,139 @@
+package v1
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"strings"
+)
+
+// RawFormat describes the memory layout of a raw framebuffer dump (e.g. of /dev/fb0)
+type RawFormat struct {
+	// PixelFormat is one of RawPixelFormats
+	PixelFormat string
+	// Width gives the number of pixels per row
+	Width int
+	// Height gives the number of rows
+	Height int
+	// Stride gives the number of bytes per row. Zero means Width times the bytes per pixel
+	Stride int
+}
+
+// rawComponent defines the position of a color component within a little-endian pixel word
+type rawComponent struct {
+	shift uint
+	bits  uint
+}
+
+// rawPixelFormat defines the size and components of a pixel. A component with zero bits is absent
+type rawPixelFormat struct {
+	bytes      int
+	r, g, b, a rawComponent
+}
+
+// rawPixelFormats follow the Linux DRM fourcc formats (drm_fourcc.h):
+// components are listed from the most to the least significant bit of a little-endian pixel word.
+// For example, XRGB8888 is stored as bytes B, G, R, X in memory.
+var rawPixelFormats = map[string]rawPixelFormat{
+	"RGB565":   {2, rawComponent{11, 5}, rawComponent{5, 6}, rawComponent{0, 5}, rawComponent{}},
+	"BGR565":   {2, rawComponent{0, 5}, rawComponent{5, 6}, rawComponent{11, 5}, rawComponent{}},
+	"XRGB1555": {2, rawComponent{10, 5}, rawComponent{5, 5}, rawComponent{0, 5}, rawComponent{}},
+	"ARGB1555": {2, rawComponent{10, 5}, rawComponent{5, 5}, rawComponent{0, 5}, rawComponent{15, 1}},
+	"RGB888":   {3, rawComponent{16, 8}, rawComponent{8, 8}, rawComponent{0, 8}, rawComponent{}},
+	"BGR888":   {3, rawComponent{0, 8}, rawComponent{8, 8}, rawComponent{16, 8}, rawComponent{}},
+	"XRGB8888": {4, rawComponent{16, 8}, rawComponent{8, 8}, rawComponent{0, 8}, rawComponent{}},
+	"ARGB8888": {4, rawComponent{16, 8}, rawComponent{8, 8}, rawComponent{0, 8}, rawComponent{24, 8}},
+	"XBGR8888": {4, rawComponent{0, 8}, rawComponent{8, 8}, rawComponent{16, 8}, rawComponent{}},
+	"ABGR8888": {4, rawComponent{0, 8}, rawComponent{8, 8}, rawComponent{16, 8}, rawComponent{24, 8}},
+	"RGBX8888": {4, rawComponent{24, 8}, rawComponent{16, 8}, rawComponent{8, 8}, rawComponent{}},
+	"RGBA8888": {4, rawComponent{24, 8}, rawComponent{16, 8}, rawComponent{8, 8}, rawComponent{0, 8}},
+	"BGRX8888": {4, rawComponent{8, 8}, rawComponent{16, 8}, rawComponent{24, 8}, rawComponent{}},
+	"BGRA8888": {4, rawComponent{8, 8}, rawComponent{16, 8}, rawComponent{24, 8}, rawComponent{0, 8}},
+}
+
+// RawPixelFormats lists all supported pixel formats of raw framebuffer dumps
+var RawPixelFormats = []string{
+	"RGB565", "BGR565", "XRGB1555", "ARGB1555", "RGB888", "BGR888",
+	"XRGB8888", "ARGB8888", "XBGR8888", "ABGR8888", "RGBX8888", "RGBA8888", "BGRX8888", "BGRA8888",
+}
+
+// RAW_LOCATION_PREFIX marks locations which are read as raw framebuffer dumps (e.g. 'raw:/dev/fb0')
+const RAW_LOCATION_PREFIX = `raw:`
+
+// Valid returns an error if the raw format cannot be used to decode a framebuffer
+func (f RawFormat) Valid() error {
+	pf, ok := rawPixelFormats[f.PixelFormat]
+	if !ok {
+		return fmt.Errorf("unknown raw pixel format '%s'; supported formats: %s", f.PixelFormat, strings.Join(RawPixelFormats, ", "))
+	}
+	if f.Width <= 0 || f.Height <= 0 {
+		return fmt.Errorf("raw framebuffer dimensions must be positive; got %d×%d", f.Width, f.Height)
+	}
+	if f.Stride != 0 && f.Stride < f.Width*pf.bytes {
+		return fmt.Errorf("raw framebuffer stride %d is smaller than width × %d bytes", f.Stride, pf.bytes)
+	}
+	return nil
+}
+
+// String returns the human-readable representation of RawFormat
+func (f RawFormat) String() string {
+	return fmt.Sprintf(`%s:%dx%d:%d`, f.PixelFormat, f.Width, f.Height, f.Stride)
+}
+
+// stride returns Stride or the minimal stride if Stride is zero
+func (f RawFormat) stride() int {
+	if f.Stride == 0 {
+		return f.Width * rawPixelFormats[f.PixelFormat].bytes
+	}
+	return f.Stride
+}
+
+// FromRawFramebuffer reads a raw framebuffer dump with the given format from r
+// and fills TaggedImage with its data. Only the first frame is read,
+// so r might provide more data (like the virtual screen of /dev/fb0).
+func (i *TaggedImage) FromRawFramebuffer(r io.Reader, f RawFormat, source string) error {
+	if err := f.Valid(); err != nil {
+		return err
+	}
+	pf := rawPixelFormats[f.PixelFormat]
+	stride := f.stride()
+
+	row := make([]byte, stride)
+	img := image.NewNRGBA(image.Rect(0, 0, f.Width, f.Height))
+	for y := 0; y < f.Height; y++ {
+		// the last row does not need padding
+		n := stride
+		if y == f.Height-1 {
+			n = f.Width * pf.bytes
+		}
+		if _, err := io.ReadFull(r, row[:n]); err != nil {
+			return fmt.Errorf("cannot read row %d of raw framebuffer '%s': %s", y, source, err)
+		}
+		for x := 0; x < f.Width; x++ {
+			var word uint32
+			for b := pf.bytes - 1; b >= 0; b-- {
+				word = word<<8 | uint32(row[x*pf.bytes+b])
+			}
+			o := img.PixOffset(x, y)
+			img.Pix[o+0] = pf.r.value(word)
+			img.Pix[o+1] = pf.g.value(word)
+			img.Pix[o+2] = pf.b.value(word)
+			img.Pix[o+3] = 0xFF
+			if pf.a.bits > 0 {
+				img.Pix[o+3] = pf.a.value(word)
+			}
+		}
+	}
+
+	i.FromImage(img, source)
+	i.Format = `raw`
+	i.BitDepth = int(pf.r.bits)
+	return nil
+}
+
+// value extracts the component from a pixel word and scales it to 8 bits
+func (c rawComponent) value(word uint32) uint8 {
+	max := uint32(1)<<c.bits - 1
+	v := (word >> c.shift) & max
+	return uint8((v*0xFF + max/2) / max)
+}
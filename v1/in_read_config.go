@@ -3,6 +3,7 @@ package v1
 import (
 	"encoding/json"
 	"fmt"
+	"image/color"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -15,6 +16,9 @@ import (
 
 const DEFAULT_CONFIG_FILE = `screenshot-compare.json`
 
+// stdinPlaceholder replaces CLI argument "-" during parsing
+const stdinPlaceholder = "\x00stdin"
+
 // NewConfig creates a new configuration struct with default values
 func NewConfig() *Config {
 	c := new(Config)
@@ -32,16 +36,64 @@ func NewConfig() *Config {
 // The return values are warnings (value not set) and errors (value cannot be used/parsed).
 // If the second return value is non-nil, Config will not be modified.
 func (c *Config) FromEnv(mode int) (error, error) {
+	baseImg, refImg, warn, err := c.fromEnv(mode)
+	if warn != nil || err != nil {
+		return warn, err
+	}
+	if mode != 3 || baseImg != "" {
+		if err := c.LoadImage(&c.BaseImg, baseImg); err != nil {
+			return nil, err
+		}
+	}
+	if mode != 3 || refImg != "" {
+		if err := c.LoadImage(&c.RefImg, refImg); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// OptionsFromEnv corresponds to FromEnv with mode=3, but never loads images, i.e.
+// SCMP_BASEIMG and SCMP_REFIMG are ignored. Commands reading their images themselves use it
+func (c *Config) OptionsFromEnv() (error, error) {
+	_, _, warn, err := c.fromEnv(3)
+	return warn, err
+}
+
+// fromEnv corresponds to FromEnv, but returns the locations of the base and reference image
+// instead of loading them
+func (c *Config) fromEnv(mode int) (string, string, error, error) {
 	s := os.Getenv(`SCMP_COLORS`)
 	t := os.Getenv(`SCMP_TIMEOUT`)
 	w := os.Getenv(`SCMP_WAIT`)
+	iv := os.Getenv(`SCMP_INTERVAL`)
+	dl := os.Getenv(`SCMP_DEADLINE`)
 	d := os.Getenv(`SCMP_DIFFPIXEL`)
 	n := os.Getenv(`SCMP_NODIMERROR`)
 	b := os.Getenv(`SCMP_BASEIMG`)
 	r := os.Getenv(`SCMP_REFIMG`)
-
-	if s != "" && s != "Y'UV" && s != "RGB" {
-		return nil, fmt.Errorf("unknown color space '%s'", s)
+	th := os.Getenv(`SCMP_THRESHOLD`)
+	me := os.Getenv(`SCMP_METRICS`)
+	l := os.Getenv(`SCMP_LUMA`)
+	am := os.Getenv(`SCMP_ALPHA`)
+	bg := os.Getenv(`SCMP_BACKGROUND`)
+	li := os.Getenv(`SCMP_LINEAR`)
+	fr := os.Getenv(`SCMP_FRAMES`)
+	ori := os.Getenv(`SCMP_ORIENT`)
+	ic := os.Getenv(`SCMP_ICC`)
+	rf := os.Getenv(`SCMP_RAW_FORMAT`)
+	rs := os.Getenv(`SCMP_RAW_SIZE`)
+	rst := os.Getenv(`SCMP_RAW_STRIDE`)
+	pd := os.Getenv(`SCMP_PENDING`)
+
+	if s != "" && !isColorSpace(s) {
+		return "", "", nil, fmt.Errorf("unknown color space '%s'", s)
+	}
+	if !isLumaStandard(l) {
+		return "", "", nil, fmt.Errorf("unknown luma standard '%s'", l)
+	}
+	if !isAlphaMode(am) {
+		return "", "", nil, fmt.Errorf("unknown alpha mode '%s'", am)
 	}
 
 	var err error
@@ -49,30 +101,81 @@ func (c *Config) FromEnv(mode int) (error, error) {
 	if t != "" {
 		to, err = parseDurationSpecifier(t)
 		if err != nil {
-			return nil, err
+			return "", "", nil, err
 		}
 	}
 	if w != "" {
 		wa, err = parseDurationSpecifier(w)
 		if err != nil {
-			return nil, err
+			return "", "", nil, err
+		}
+	}
+	var interval, deadline time.Duration
+	if iv != "" {
+		interval, err = parseDurationSpecifier(iv)
+		if err != nil {
+			return "", "", nil, err
+		}
+	}
+	if dl != "" {
+		deadline, err = parseDurationSpecifier(dl)
+		if err != nil {
+			return "", "", nil, err
 		}
 	}
 	var diffpixel uint
 	if d != "" {
 		diffu64, err := strconv.ParseUint(d, 10, 32)
 		if err != nil {
-			return nil, err
+			return "", "", nil, err
 		}
 		diffpixel = uint(diffu64)
 	}
-	var nodimerr bool
-	if strings.ToLower(n) == `true` || strings.ToLower(n) == `yes` {
-		nodimerr = true
-	} else if strings.ToLower(n) == `false` || strings.ToLower(n) == `no` || n == `` {
-		nodimerr = false
-	} else {
-		return nil, fmt.Errorf(`invalid value for env variable SCMP_NODIMERROR, expected 'true' or 'false', got '%s'`, n)
+	nodimerr, err := parseBoolEnv(`SCMP_NODIMERROR`, n)
+	if err != nil {
+		return "", "", nil, err
+	}
+	linear, err := parseBoolEnv(`SCMP_LINEAR`, li)
+	if err != nil {
+		return "", "", nil, err
+	}
+	frames, err := parseBoolEnv(`SCMP_FRAMES`, fr)
+	if err != nil {
+		return "", "", nil, err
+	}
+	orient, err := parseBoolEnv(`SCMP_ORIENT`, ori)
+	if err != nil {
+		return "", "", nil, err
+	}
+	icc, err := parseBoolEnv(`SCMP_ICC`, ic)
+	if err != nil {
+		return "", "", nil, err
+	}
+	var threshold *float64
+	if th != "" {
+		t, err := parseThreshold(th)
+		if err != nil {
+			return "", "", nil, err
+		}
+		threshold = &t
+	}
+	var background color.Color
+	if bg != "" {
+		background, err = parseColorSpecifier(bg)
+		if err != nil {
+			return "", "", nil, err
+		}
+	}
+	raw, err := parseRawFormat(rf, rs, rst)
+	if err != nil {
+		return "", "", nil, err
+	}
+	var metrics []MetricSpec
+	if me != "" {
+		metrics, err = parseMetricSpecs(me)
+		if err != nil {
+			return "", "", nil, err
+		}
 	}
 
 	switch mode {
@@ -80,64 +183,311 @@ func (c *Config) FromEnv(mode int) (error, error) {
 		envs := []string{`SCMP_COLORS`, `SCMP_TIMEOUT`, `SCMP_WAIT`, `SCMP_DIFFPIXEL`, `SCMP_NODIMERROR`, `SCMP_BASEIMG`, `SCMP_REFIMG`}
 		for _, env := range envs {
 			if os.Getenv(env) == "" {
-				return fmt.Errorf(`environment variable %s not set`, env), nil
+				return "", "", fmt.Errorf(`environment variable %s not set`, env), nil
 			}
 		}
 		c.ColorSpace = s
+		c.Luma = l
 		c.Timeout = to
 		c.PreWait = wa
+		c.Interval = interval
+		c.Deadline = deadline
 		c.AdmissibleDiffPixel = diffpixel
 		c.NoDimensionError = nodimerr
-		if err := c.BaseImg.FromFilepath(b); err != nil {
-			return nil, err
-		}
-		if err := c.RefImg.FromFilepath(r); err != nil {
-			return nil, err
-		}
+		c.Linear = linear
+		c.Frames = frames
+		c.Orient = orient
+		c.ICC = icc
+		c.Raw = raw
+		c.Threshold = threshold
+		c.AlphaMode = am
+		c.Background = background
+		c.Metrics = metrics
+		c.Pending = pd
 
 	case 2:
 		if b == "" {
-			return fmt.Errorf(`environment variable SCMP_BASEIMG not set`), nil
+			return "", "", fmt.Errorf(`environment variable SCMP_BASEIMG not set`), nil
 		} else if r == "" {
-			return fmt.Errorf(`environment variable SCMP_REFIMG not set`), nil
+			return "", "", fmt.Errorf(`environment variable SCMP_REFIMG not set`), nil
 		}
 		c.ColorSpace = s
+		c.Luma = l
 		c.Timeout = to
 		c.PreWait = wa
+		c.Interval = interval
+		c.Deadline = deadline
 		c.AdmissibleDiffPixel = diffpixel
 		c.NoDimensionError = nodimerr
-		if err := c.BaseImg.FromFilepath(b); err != nil {
-			return nil, err
-		}
-		if err := c.RefImg.FromFilepath(r); err != nil {
-			return nil, err
-		}
+		c.Linear = linear
+		c.Frames = frames
+		c.Orient = orient
+		c.ICC = icc
+		c.Raw = raw
+		c.Threshold = threshold
+		c.AlphaMode = am
+		c.Background = background
+		c.Metrics = metrics
+		c.Pending = pd
 
 	case 3:
 		if s != "" {
 			c.ColorSpace = s
 		}
+		if l != "" {
+			c.Luma = l
+		}
 		if t != "" {
 			c.Timeout = to
 		}
 		if w != "" {
 			c.PreWait = wa
 		}
+		if iv != "" {
+			c.Interval = interval
+		}
+		if dl != "" {
+			c.Deadline = deadline
+		}
 		if d != "" {
 			c.AdmissibleDiffPixel = diffpixel
 		}
 		if n != "" {
 			c.NoDimensionError = nodimerr
 		}
-		if b != "" {
-			if err := c.BaseImg.FromFilepath(b); err != nil {
-				return nil, err
-			}
+		if li != "" {
+			c.Linear = linear
 		}
-		if r != "" {
-			if err := c.RefImg.FromFilepath(r); err != nil {
-				return nil, err
-			}
+		if fr != "" {
+			c.Frames = frames
+		}
+		if ori != "" {
+			c.Orient = orient
+		}
+		if ic != "" {
+			c.ICC = icc
+		}
+		if rf != "" {
+			c.Raw = raw
+		}
+		if th != "" {
+			c.Threshold = threshold
+		}
+		if am != "" {
+			c.AlphaMode = am
+		}
+		if bg != "" {
+			c.Background = background
+		}
+		if me != "" {
+			c.Metrics = metrics
+		}
+		if pd != "" {
+			c.Pending = pd
+		}
+
+	default:
+		return "", "", nil, fmt.Errorf(`mode must be one of 1, 2, and 3; got '%d'`, mode)
+	}
+
+	return b, r, nil, nil
+}
+
+// ConfigFlags holds the CLI flags of all comparison options registered by RegisterFlags.
+// After parsing the CLI arguments, Config.FromFlags stores their values in a Config struct.
+type ConfigFlags struct {
+	colorSpace          *string
+	luma                *string
+	timeout             *time.Duration
+	preWait             *time.Duration
+	interval            *time.Duration
+	deadline            *time.Duration
+	admissibleDiffPixel *uint
+	nodimerror          *bool
+	linear              *bool
+	frames              *bool
+	orient              *bool
+	icc                 *bool
+	threshold           *string
+	alphaMode           *string
+	bgColor             *string
+	rawFormat           *string
+	rawSize             *string
+	rawStride           *string
+	metrics             *string
+	pending             *string
+}
+
+// RegisterFlags registers CLI flags for all comparison options at the given kingpin application.
+// CLI commands use it to accept the options of the comparison next to their own positional arguments.
+func RegisterFlags(cli *kingpin.Application) *ConfigFlags {
+	return &ConfigFlags{
+		colorSpace:          cli.Flag("colors", `color space, one of "Y'UV", "RGB", "Gray", "R", "G", "B" and "Y", default is "RGB"`).Short('c').String(),
+		luma:                cli.Flag("luma", `luma weights for color space "Gray", one of "BT.601" and "BT.709"`).String(),
+		timeout:             cli.Flag("timeout", `maximum time comparison is allowed to take, 0s is infinite, e.g. '1s'`).Default("0s").Short('t').Duration(),
+		preWait:             cli.Flag("wait", `duration to wait before comparison starts, e.g. '200ms'`).Default("0s").Short('w').Duration(),
+		interval:            cli.Flag("interval", `duration between two attempts of command wait, e.g. '500ms'`).Default("0s").Duration(),
+		deadline:            cli.Flag("deadline", `maximum duration of command wait, 0s is infinite, e.g. '2m'`).Default("0s").Duration(),
+		admissibleDiffPixel: cli.Flag("diffpixel", `fixed number of pixels with difference to ignore`).Short('d').Uint(),
+		nodimerror:          cli.Flag("nodimerror", `if true, max diff will be returned if dimensions don't match instead of error`).Short('n').Bool(),
+		linear:              cli.Flag("linear", `if true, sRGB values are linearised before computing distances`).Short('l').Bool(),
+		frames:              cli.Flag("frames", `if true, all frames of animated GIF and APNG images are compared`).Short('f').Bool(),
+		orient:              cli.Flag("orient", `if true, images are rotated and flipped according to their EXIF orientation`).Bool(),
+		icc:                 cli.Flag("icc", `if true, images with a Display P3 or Adobe RGB color profile are converted to sRGB`).Bool(),
+		threshold:           cli.Flag("threshold", `maximum score for the images to match, e.g. '0.1'`).String(),
+		alphaMode:           cli.Flag("alpha", `alpha mode, one of "ref-mask", "both-mask", "composite-over-background" and "compare-alpha-as-channel"`).Short('a').String(),
+		bgColor:             cli.Flag("background", `background color for alpha mode "composite-over-background", e.g. '#ffffff'`).String(),
+		rawFormat:           cli.Flag("raw-format", `pixel format of raw framebuffer dumps given as 'raw:<filepath>', e.g. 'XRGB8888'`).String(),
+		rawSize:             cli.Flag("raw-size", `dimensions of raw framebuffer dumps, e.g. '1024x768'`).String(),
+		rawStride:           cli.Flag("raw-stride", `bytes per row of raw framebuffer dumps, default is width × bytes per pixel`).String(),
+		metrics:             cli.Flag("metrics", `comma-separated metrics, e.g. 'pixel:Y'UV:2:0.05,ssim,histogram'`).Short('m').String(),
+		pending:             cli.Flag("pending", `directory to record failed comparisons in for approval`).String(),
+	}
+}
+
+// ParseArgs parses the given arguments (without program name) with the given kingpin application.
+// Unlike kingpin.Application.Parse, it accepts "-" as positional argument and does not terminate
+// the program if the arguments are invalid. LoadImage reads such arguments from standard input.
+func ParseArgs(cli *kingpin.Application, args []string) error {
+	var err error
+	cli.Terminate(func(int) {
+		err = fmt.Errorf(`invalid CLI call`)
+	})
+
+	// kingpin rejects "-" as empty short flag, hence pass a placeholder for stdin
+	argv := make([]string, 0, len(args))
+	for _, arg := range args {
+		if arg == "-" {
+			arg = stdinPlaceholder
+		}
+		argv = append(argv, arg)
+	}
+	if _, err2 := cli.Parse(argv); err2 != nil {
+		return err2
+	}
+	return err
+}
+
+// FromFlags stores the values of CLI flags registered by RegisterFlags in its Config struct.
+// The images of Config are not modified.
+// If mode=1, all values must be set or an error is returned. Depending on the type, it might not be possible to distinguish between 'not set' and 'zero value'.
+// If mode=2, all values will be stored. If mode=3, any non-zero value will be stored.
+// The return values are warnings (value not set) and errors (value cannot be used/parsed).
+// If the second return value is non-nil, Config will not be modified.
+func (c *Config) FromFlags(f *ConfigFlags, mode int) (error, error) {
+	var err error
+	if *f.colorSpace != "" && !isColorSpace(*f.colorSpace) {
+		return nil, fmt.Errorf("unknown color space '%s'", *f.colorSpace)
+	}
+	if !isLumaStandard(*f.luma) {
+		return nil, fmt.Errorf("unknown luma standard '%s'", *f.luma)
+	}
+	var threshold *float64
+	if *f.threshold != "" {
+		t, err := parseThreshold(*f.threshold)
+		if err != nil {
+			return nil, err
+		}
+		threshold = &t
+	}
+	if !isAlphaMode(*f.alphaMode) {
+		return nil, fmt.Errorf("unknown alpha mode '%s'", *f.alphaMode)
+	}
+	var background color.Color
+	if *f.bgColor != "" {
+		background, err = parseColorSpecifier(*f.bgColor)
+		if err != nil {
+			return nil, err
+		}
+	}
+	raw, err := parseRawFormat(*f.rawFormat, *f.rawSize, *f.rawStride)
+	if err != nil {
+		return nil, err
+	}
+	metricSpecs, err := parseMetricSpecs(*f.metrics)
+	if err != nil {
+		return nil, err
+	}
+
+	switch mode {
+	case 1, 2:
+		// --colors has no kingpin default, so mode 3 keeps color spaces of other sources
+		c.ColorSpace = *f.colorSpace
+		if c.ColorSpace == "" {
+			c.ColorSpace = `RGB`
+		}
+		c.Luma = *f.luma
+		c.Timeout = *f.timeout
+		c.PreWait = *f.preWait
+		c.Interval = *f.interval
+		c.Deadline = *f.deadline
+		c.AdmissibleDiffPixel = *f.admissibleDiffPixel
+		c.NoDimensionError = *f.nodimerror
+		c.Linear = *f.linear
+		c.Frames = *f.frames
+		c.Orient = *f.orient
+		c.ICC = *f.icc
+		c.Raw = raw
+		c.Threshold = threshold
+		c.AlphaMode = *f.alphaMode
+		c.Background = background
+		c.Metrics = metricSpecs
+		c.Pending = *f.pending
+
+	case 3:
+		if *f.colorSpace != "" {
+			c.ColorSpace = *f.colorSpace
+		}
+		if *f.luma != "" {
+			c.Luma = *f.luma
+		}
+		if *f.timeout != 0 {
+			c.Timeout = *f.timeout
+		}
+		if *f.preWait != 0 {
+			c.PreWait = *f.preWait
+		}
+		if *f.interval != 0 {
+			c.Interval = *f.interval
+		}
+		if *f.deadline != 0 {
+			c.Deadline = *f.deadline
+		}
+		if *f.admissibleDiffPixel != 0 {
+			c.AdmissibleDiffPixel = *f.admissibleDiffPixel
+		}
+		if *f.nodimerror != false {
+			c.NoDimensionError = *f.nodimerror
+		}
+		if *f.linear != false {
+			c.Linear = *f.linear
+		}
+		if *f.frames != false {
+			c.Frames = *f.frames
+		}
+		if *f.orient != false {
+			c.Orient = *f.orient
+		}
+		if *f.icc != false {
+			c.ICC = *f.icc
+		}
+		if *f.rawFormat != "" {
+			c.Raw = raw
+		}
+		if threshold != nil {
+			c.Threshold = threshold
+		}
+		if *f.alphaMode != "" {
+			c.AlphaMode = *f.alphaMode
+		}
+		if *f.bgColor != "" {
+			c.Background = background
+		}
+		if len(metricSpecs) > 0 {
+			c.Metrics = metricSpecs
+		}
+		if *f.pending != "" {
+			c.Pending = *f.pending
 		}
 
 	default:
@@ -153,106 +503,49 @@ func (c *Config) FromEnv(mode int) (error, error) {
 // The return values are warnings (value not set) and errors (value cannot be used/parsed).
 // If the second return value is non-nil, Config will not be modified.
 func (c *Config) FromArgs(args []string, usage string, mode int) (error, error) {
-	var err error
-	terminate := func(int) {
-		err = fmt.Errorf(`invalid CLI call`)
-	}
-
 	// kingpin calls
 	cli := kingpin.New(filepath.Base(args[0]), usage)
-	colorSpace := cli.Flag("colors", `color space, one of "Y'UV" and "RGB"`).Default("RGB").Short('c').String()
-	timeout := cli.Flag("timeout", `maximum time comparison is allowed to take, 0s is infinite, e.g. '1s'`).Default("0s").Short('t').Duration()
-	preWait := cli.Flag("wait", `duration to wait before comparison starts, e.g. '200ms'`).Default("0s").Short('w').Duration()
-	admissibleDiffPixel := cli.Flag("diffpixel", `fixed number of pixels with difference to ignore`).Short('d').Uint()
-	nodimerror := cli.Flag("nodimerror", `if true, max diff will be returned if dimensions don't match instead of error`).Short('n').Bool()
+	flags := RegisterFlags(cli)
 	baseImg := cli.Arg("baseimg", `filepath to image to compare`).Required().String()
 	refImg := cli.Arg("refimg", `filepath to image to compare with`).Required().String()
-
 	cli.Version("1.2.0")
-	cli.Terminate(terminate)
-	_, err2 := cli.Parse(args[1:])
-	if err2 != nil {
-		return nil, err2
-	}
-	if err != nil {
+
+	if err := ParseArgs(cli, args[1:]); err != nil {
 		return nil, err
 	}
 
 	// no errors returned by kingpin, use the values
-	if *colorSpace != "" && *colorSpace != "Y'UV" && *colorSpace != "RGB" {
-		return nil, fmt.Errorf("unknown color space '%s'", *colorSpace)
+	if *baseImg == stdinPlaceholder && *refImg == stdinPlaceholder {
+		return nil, fmt.Errorf(`only one of baseimg and refimg can be read from stdin`)
 	}
-
 	switch mode {
 	case 1:
-		if *colorSpace == "" {
-			return fmt.Errorf(`missing CLI argument --colors`), nil
-		}
 		if *baseImg == "" {
 			return fmt.Errorf(`missing CLI argument --baseimg`), nil
 		}
 		if *refImg == "" {
 			return fmt.Errorf(`missing CLI argument --refimg`), nil
 		}
-		c.ColorSpace = *colorSpace
-		c.Timeout = *timeout
-		c.PreWait = *preWait
-		c.AdmissibleDiffPixel = *admissibleDiffPixel
-		c.NoDimensionError = *nodimerror
-		if err := c.BaseImg.FromFilepath(*baseImg); err != nil {
-			return nil, err
-		}
-		if err := c.RefImg.FromFilepath(*refImg); err != nil {
-			return nil, err
-		}
-
 	case 2:
 		if *baseImg == "" {
 			return fmt.Errorf(`CLI argument --baseimg not set`), nil
 		} else if *refImg == "" {
 			return fmt.Errorf(`CLI argument --refimg not set`), nil
 		}
-		c.ColorSpace = *colorSpace
-		c.Timeout = *timeout
-		c.PreWait = *preWait
-		c.AdmissibleDiffPixel = *admissibleDiffPixel
-		c.NoDimensionError = *nodimerror
-		if err := c.BaseImg.FromFilepath(*baseImg); err != nil {
+	}
+	if warn, err := c.FromFlags(flags, mode); warn != nil || err != nil {
+		return warn, err
+	}
+
+	if mode != 3 || *baseImg != "" {
+		if err := c.LoadImage(&c.BaseImg, *baseImg); err != nil {
 			return nil, err
 		}
-		if err := c.RefImg.FromFilepath(*refImg); err != nil {
+	}
+	if mode != 3 || *refImg != "" {
+		if err := c.LoadImage(&c.RefImg, *refImg); err != nil {
 			return nil, err
 		}
-
-	case 3:
-		if *colorSpace != "" {
-			c.ColorSpace = *colorSpace
-		}
-		if *timeout != 0 {
-			c.Timeout = *timeout
-		}
-		if *preWait != 0 {
-			c.PreWait = *preWait
-		}
-		if *admissibleDiffPixel != 0 {
-			c.AdmissibleDiffPixel = *admissibleDiffPixel
-		}
-		if *nodimerror != false {
-			c.NoDimensionError = *nodimerror
-		}
-		if *baseImg != "" {
-			if err := c.BaseImg.FromFilepath(*baseImg); err != nil {
-				return nil, err
-			}
-		}
-		if *refImg != "" {
-			if err := c.RefImg.FromFilepath(*refImg); err != nil {
-				return nil, err
-			}
-		}
-
-	default:
-		return nil, fmt.Errorf(`mode must be one of 1, 2, and 3; got '%d'`, mode)
 	}
 
 	return nil, nil
@@ -266,124 +559,335 @@ func (c *Config) FromArgs(args []string, usage string, mode int) (error, error)
 // The return values are warnings (value not set) and errors (value cannot be used/parsed).
 // If the second return value is non-nil, Config will not be modified.
 func (c *Config) FromJSON(filepath string, silentMissingError bool, mode int) (error, error) {
+	data, warn, err := readJSONFile(filepath, silentMissingError)
+	if warn != nil || err != nil || data == nil {
+		return warn, err
+	}
+	baseImg, refImg, warn, err := c.fromJSONData(data, mode)
+	if warn != nil || err != nil {
+		return warn, err
+	}
+	if mode != 3 || baseImg != "" {
+		if err := c.LoadImage(&c.BaseImg, baseImg); err != nil {
+			return nil, err
+		}
+	}
+	if mode != 3 || refImg != "" {
+		if err := c.LoadImage(&c.RefImg, refImg); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
+// OptionsFromJSON corresponds to FromJSON with mode=3, but never loads images, i.e.
+// baseimg and refimg are ignored. Commands reading their images themselves use it
+func (c *Config) OptionsFromJSON(filepath string, silentMissingError bool) (error, error) {
+	data, warn, err := readJSONFile(filepath, silentMissingError)
+	if warn != nil || err != nil || data == nil {
+		return warn, err
+	}
+	_, _, warn, err = c.fromJSONData(data, 3)
+	return warn, err
+}
+
+// readJSONFile reads the JSON configuration file at the given filepath (DEFAULT_CONFIG_FILE if empty).
+// If silentMissingError is true, a missing file gives no data and no warning
+func readJSONFile(filepath string, silentMissingError bool) ([]byte, error, error) {
 	if filepath == "" {
 		filepath = DEFAULT_CONFIG_FILE
 	}
 	if _, err := os.Stat(filepath); os.IsNotExist(err) {
 		if silentMissingError {
-			return nil, nil
-		} else {
-			return fmt.Errorf("configuration file '%s' does not exist", filepath), nil
+			return nil, nil, nil
 		}
+		return nil, fmt.Errorf("configuration file '%s' does not exist", filepath), nil
+	}
+
+	data, err := ioutil.ReadFile(filepath)
+	if err != nil {
+		return nil, nil, err
 	}
+	return data, nil, nil
+}
 
+// fromJSONData corresponds to FromJSON, but parses the given JSON data.
+// Instead of loading the images, it returns the locations of the base and reference image
+func (c *Config) fromJSONData(data []byte, mode int) (string, string, error, error) {
 	// json struct
 	type jsonConfig struct {
-		Colors     string `json:"colors,omitempty"`
-		Timeout    string `json:"timeout,omitempty"`
-		PreWait    string `json:"wait,omitempty"`
-		DiffPixel  uint   `json:"diffpixel,omitempty"`
-		NoDimError bool   `json:"nodimerror,omitempty"`
-		BaseImg    string `json:"baseimg,omitempty"`
-		RefImg     string `json:"refimg,omitempty"`
+		Colors     string       `json:"colors,omitempty"`
+		Luma       string       `json:"luma,omitempty"`
+		Timeout    string       `json:"timeout,omitempty"`
+		PreWait    string       `json:"wait,omitempty"`
+		Interval   string       `json:"interval,omitempty"`
+		Deadline   string       `json:"deadline,omitempty"`
+		DiffPixel  uint         `json:"diffpixel,omitempty"`
+		NoDimError bool         `json:"nodimerror,omitempty"`
+		Linear     bool         `json:"linear,omitempty"`
+		Frames     bool         `json:"frames,omitempty"`
+		Orient     bool         `json:"orient,omitempty"`
+		ICC        bool         `json:"icc,omitempty"`
+		RawFormat  string       `json:"rawformat,omitempty"`
+		RawSize    string       `json:"rawsize,omitempty"`
+		RawStride  string       `json:"rawstride,omitempty"`
+		Threshold  *float64     `json:"threshold,omitempty"`
+		AlphaMode  string       `json:"alpha,omitempty"`
+		Background string       `json:"background,omitempty"`
+		Metrics    []MetricSpec `json:"metrics,omitempty"`
+		Pending    string       `json:"pending,omitempty"`
+		BaseImg    string       `json:"baseimg,omitempty"`
+		RefImg     string       `json:"refimg,omitempty"`
 	}
 	var jsonConf jsonConfig
-	jBytes, err := ioutil.ReadFile(filepath)
+	err := json.Unmarshal(data, &jsonConf)
 	if err != nil {
-		return nil, err
-	}
-	err = json.Unmarshal(jBytes, &jsonConf)
-	if err != nil {
-		return nil, err
+		return "", "", nil, err
 	}
 
 	var to, wa time.Duration
 	if jsonConf.Timeout != "" {
 		to, err = parseDurationSpecifier(jsonConf.Timeout)
 		if err != nil {
-			return nil, err
+			return "", "", nil, err
 		}
 	}
 	if jsonConf.PreWait != "" {
 		wa, err = parseDurationSpecifier(jsonConf.PreWait)
 		if err != nil {
-			return nil, err
+			return "", "", nil, err
 		}
 	}
-	if jsonConf.Colors != "" && jsonConf.Colors != "Y'UV" && jsonConf.Colors != "RGB" {
-		return nil, fmt.Errorf("unknown color space '%s'", jsonConf.Colors)
+	var interval, deadline time.Duration
+	if jsonConf.Interval != "" {
+		interval, err = parseDurationSpecifier(jsonConf.Interval)
+		if err != nil {
+			return "", "", nil, err
+		}
+	}
+	if jsonConf.Deadline != "" {
+		deadline, err = parseDurationSpecifier(jsonConf.Deadline)
+		if err != nil {
+			return "", "", nil, err
+		}
+	}
+	if jsonConf.Colors != "" && !isColorSpace(jsonConf.Colors) {
+		return "", "", nil, fmt.Errorf("unknown color space '%s'", jsonConf.Colors)
+	}
+	if !isLumaStandard(jsonConf.Luma) {
+		return "", "", nil, fmt.Errorf("unknown luma standard '%s'", jsonConf.Luma)
+	}
+	if !validThreshold(jsonConf.Threshold) {
+		return "", "", nil, fmt.Errorf("threshold must be between 0 and 1; got '%g'", *jsonConf.Threshold)
+	}
+	if !isAlphaMode(jsonConf.AlphaMode) {
+		return "", "", nil, fmt.Errorf("unknown alpha mode '%s'", jsonConf.AlphaMode)
+	}
+	var background color.Color
+	if jsonConf.Background != "" {
+		background, err = parseColorSpecifier(jsonConf.Background)
+		if err != nil {
+			return "", "", nil, err
+		}
+	}
+	raw, err := parseRawFormat(jsonConf.RawFormat, jsonConf.RawSize, jsonConf.RawStride)
+	if err != nil {
+		return "", "", nil, err
+	}
+	for _, m := range jsonConf.Metrics {
+		if err := m.Valid(); err != nil {
+			return "", "", nil, err
+		}
 	}
 
 	switch mode {
 	case 1:
 		if jsonConf.Colors == "" {
-			return fmt.Errorf(`missing JSON parameter colors`), nil
+			return "", "", fmt.Errorf(`missing JSON parameter colors`), nil
 		}
 		if jsonConf.BaseImg == "" || jsonConf.RefImg == "" {
-			return fmt.Errorf(`missing JSON parameter baseimg or refimg`), nil
+			return "", "", fmt.Errorf(`missing JSON parameter baseimg or refimg`), nil
 		}
 		c.ColorSpace = jsonConf.Colors
+		c.Luma = jsonConf.Luma
 		c.Timeout = to
 		c.PreWait = wa
+		c.Interval = interval
+		c.Deadline = deadline
 		c.AdmissibleDiffPixel = jsonConf.DiffPixel
 		c.NoDimensionError = jsonConf.NoDimError
-		if err := c.BaseImg.FromFilepath(jsonConf.BaseImg); err != nil {
-			return nil, err
-		}
-		if err := c.RefImg.FromFilepath(jsonConf.RefImg); err != nil {
-			return nil, err
-		}
+		c.Linear = jsonConf.Linear
+		c.Frames = jsonConf.Frames
+		c.Orient = jsonConf.Orient
+		c.ICC = jsonConf.ICC
+		c.Raw = raw
+		c.Threshold = jsonConf.Threshold
+		c.AlphaMode = jsonConf.AlphaMode
+		c.Background = background
+		c.Metrics = jsonConf.Metrics
+		c.Pending = jsonConf.Pending
 
 	case 2:
 		if jsonConf.BaseImg == "" {
-			return fmt.Errorf(`missing JSON parameter baseimg`), nil
+			return "", "", fmt.Errorf(`missing JSON parameter baseimg`), nil
 		} else if jsonConf.RefImg == "" {
-			return fmt.Errorf(`missing JSON parameter refimg`), nil
+			return "", "", fmt.Errorf(`missing JSON parameter refimg`), nil
 		}
 		c.ColorSpace = jsonConf.Colors
+		c.Luma = jsonConf.Luma
 		c.Timeout = to
 		c.PreWait = wa
+		c.Interval = interval
+		c.Deadline = deadline
 		c.AdmissibleDiffPixel = jsonConf.DiffPixel
 		c.NoDimensionError = jsonConf.NoDimError
-		if err := c.BaseImg.FromFilepath(jsonConf.BaseImg); err != nil {
-			return nil, err
-		}
-		if err := c.RefImg.FromFilepath(jsonConf.RefImg); err != nil {
-			return nil, err
-		}
+		c.Linear = jsonConf.Linear
+		c.Frames = jsonConf.Frames
+		c.Orient = jsonConf.Orient
+		c.ICC = jsonConf.ICC
+		c.Raw = raw
+		c.Threshold = jsonConf.Threshold
+		c.AlphaMode = jsonConf.AlphaMode
+		c.Background = background
+		c.Metrics = jsonConf.Metrics
+		c.Pending = jsonConf.Pending
 
 	case 3:
 		if jsonConf.Colors != "" {
 			c.ColorSpace = jsonConf.Colors
 		}
+		if jsonConf.Luma != "" {
+			c.Luma = jsonConf.Luma
+		}
 		if jsonConf.Timeout != "" {
 			c.Timeout = to
 		}
 		if jsonConf.PreWait != "" {
 			c.PreWait = wa
 		}
+		if jsonConf.Interval != "" {
+			c.Interval = interval
+		}
+		if jsonConf.Deadline != "" {
+			c.Deadline = deadline
+		}
 		if jsonConf.DiffPixel != 0 {
 			c.AdmissibleDiffPixel = jsonConf.DiffPixel
 		}
 		if jsonConf.NoDimError {
 			c.NoDimensionError = jsonConf.NoDimError
 		}
-		if jsonConf.BaseImg != "" {
-			if err := c.BaseImg.FromFilepath(jsonConf.BaseImg); err != nil {
-				return nil, err
-			}
+		if jsonConf.Linear {
+			c.Linear = jsonConf.Linear
 		}
-		if jsonConf.RefImg != "" {
-			if err := c.RefImg.FromFilepath(jsonConf.RefImg); err != nil {
-				return nil, err
-			}
+		if jsonConf.Frames {
+			c.Frames = jsonConf.Frames
+		}
+		if jsonConf.Orient {
+			c.Orient = jsonConf.Orient
+		}
+		if jsonConf.ICC {
+			c.ICC = jsonConf.ICC
+		}
+		if jsonConf.RawFormat != "" {
+			c.Raw = raw
+		}
+		if jsonConf.Threshold != nil {
+			c.Threshold = jsonConf.Threshold
+		}
+		if jsonConf.AlphaMode != "" {
+			c.AlphaMode = jsonConf.AlphaMode
+		}
+		if jsonConf.Background != "" {
+			c.Background = background
+		}
+		if len(jsonConf.Metrics) > 0 {
+			c.Metrics = jsonConf.Metrics
+		}
+		if jsonConf.Pending != "" {
+			c.Pending = jsonConf.Pending
 		}
 
 	default:
-		return nil, fmt.Errorf(`mode must be one of 1, 2, and 3; got '%d'`, mode)
+		return "", "", nil, fmt.Errorf(`mode must be one of 1, 2, and 3; got '%d'`, mode)
 	}
 
-	return nil, nil
+	return jsonConf.BaseImg, jsonConf.RefImg, nil, nil
+}
+
+// parseBoolEnv takes the value of the environment variable `name`
+// and returns true for 'true' and 'yes' and false for 'false', 'no' and the empty string
+func parseBoolEnv(name, value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case `true`, `yes`:
+		return true, nil
+	case `false`, `no`, ``:
+		return false, nil
+	}
+	return false, fmt.Errorf(`invalid value for env variable %s, expected 'true' or 'false', got '%s'`, name, value)
+}
+
+// parseThreshold takes a threshold like '0.05' and
+// returns its value if it is between 0 and 1
+func parseThreshold(s string) (float64, error) {
+	t, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0.0, fmt.Errorf("invalid threshold; expected number between 0 and 1; got '%s'", s)
+	}
+	if t < 0.0 || t > 1.0 {
+		return 0.0, fmt.Errorf("threshold must be between 0 and 1; got '%s'", s)
+	}
+	return t, nil
+}
+
+// parseRawFormat takes a pixel format like 'XRGB8888', a size specifier
+// like '1024x768' and an optional stride like '4096' and returns the RawFormat.
+// If all values are empty, the zero RawFormat is returned.
+func parseRawFormat(format, size, stride string) (RawFormat, error) {
+	var f RawFormat
+	if format == "" && size == "" && stride == "" {
+		return f, nil
+	}
+	errmsg := "invalid raw framebuffer size specifier; expected '<width>x<height>'; got '%s'"
+
+	f.PixelFormat = strings.ToUpper(strings.TrimSpace(format))
+	dims := strings.Split(strings.ToLower(strings.TrimSpace(size)), "x")
+	if len(dims) != 2 {
+		return f, fmt.Errorf(errmsg, size)
+	}
+	var err error
+	if f.Width, err = strconv.Atoi(dims[0]); err != nil {
+		return f, fmt.Errorf(errmsg, size)
+	}
+	if f.Height, err = strconv.Atoi(dims[1]); err != nil {
+		return f, fmt.Errorf(errmsg, size)
+	}
+	if stride != "" {
+		if f.Stride, err = strconv.Atoi(strings.TrimSpace(stride)); err != nil {
+			return f, fmt.Errorf("invalid raw framebuffer stride; expected integer; got '%s'", stride)
+		}
+	}
+	return f, f.Valid()
+}
+
+// parseColorSpecifier takes a hexadecimal color specifier
+// like '#ff8000' or '#ff800080' and returns the corresponding color
+func parseColorSpecifier(s string) (color.Color, error) {
+	s = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(s)), "#")
+	errmsg := "invalid color specifier; expected '#rrggbb' or '#rrggbbaa'; got '%s'"
+
+	if len(s) != 6 && len(s) != 8 {
+		return nil, fmt.Errorf(errmsg, s)
+	}
+	if len(s) == 6 {
+		s += "ff"
+	}
+	val, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf(errmsg, s)
+	}
+	return color.NRGBA{uint8(val >> 24), uint8(val >> 16), uint8(val >> 8), uint8(val)}, nil
 }
 
 // parseDurationSpecifier takes a human-readable duration specifier
@@ -0,0 +1,130 @@
+package v1
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+// Remask transfers the alpha channel (mask) of a reference image onto Image, e.g. onto a new
+// screenshot replacing a hand-made masked reference image. The dimensions must correspond.
+// If maxShift is positive, the mask is realigned first: the offset of at most maxShift pixels
+// in each direction is determined at which the opaque area of ref matches Image best, and the
+// mask is moved by this offset. Areas uncovered by a moved mask stay opaque.
+// The offset applied to the mask is returned
+func (i *TaggedImage) Remask(ref *TaggedImage, maxShift int) (image.Point, error) {
+	if i.Width != ref.Width || i.Height != ref.Height {
+		return image.Point{}, fmt.Errorf("image dimensions do not correspond; got %d×%d (image) and %d×%d (reference '%s')", i.Width, i.Height, ref.Width, ref.Height, ref.Source)
+	}
+	if maxShift < 0 {
+		return image.Point{}, fmt.Errorf("maximum shift must not be negative; got %d", maxShift)
+	}
+
+	var offset image.Point
+	if maxShift > 0 {
+		offset = maskOffset(i, ref, maxShift)
+	}
+	if offset == (image.Point{}) {
+		return offset, i.ApplyMask(ref)
+	}
+
+	var mask TaggedImage
+	mask.FromImage(shiftAlpha(ref, offset), fmt.Sprintf("%s@%+d%+d", ref.Source, offset.X, offset.Y))
+	return offset, i.ApplyMask(&mask)
+}
+
+// lumaAlpha returns the luma and alpha (between 0 and 1) of every pixel of the image in row-major order
+func lumaAlpha(img *TaggedImage) ([]float64, []float64) {
+	b := img.Image.Bounds()
+	luma := make([]float64, img.Width*img.Height)
+	alpha := make([]float64, img.Width*img.Height)
+	for y := 0; y < img.Height; y++ {
+		for x := 0; x < img.Width; x++ {
+			c := color.NRGBA64Model.Convert(img.Image.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA64)
+			luma[y*img.Width+x] = (0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)) / 0xFFFF
+			alpha[y*img.Width+x] = float64(c.A) / 0xFFFF
+		}
+	}
+	return luma, alpha
+}
+
+// maskOffset returns the offset of at most maxShift pixels in each direction at which
+// the opaque area of ref has the lowest mean squared luma difference to img.
+// At least half of the opaque area must overlap img. Ties are resolved by the shorter offset
+func maskOffset(img, ref *TaggedImage, maxShift int) image.Point {
+	imgLuma, _ := lumaAlpha(img)
+	refLuma, refAlpha := lumaAlpha(ref)
+	total := 0.0
+	for _, a := range refAlpha {
+		total += a
+	}
+	if total == 0.0 {
+		return image.Point{}
+	}
+
+	side := 2*maxShift + 1
+	scores := make([]float64, side*side)
+	parallelize(len(scores), func(k int) {
+		dx, dy := k%side-maxShift, k/side-maxShift
+		sum, weight := 0.0, 0.0
+		for y := 0; y < ref.Height; y++ {
+			if y+dy < 0 || y+dy >= img.Height {
+				continue
+			}
+			for x := 0; x < ref.Width; x++ {
+				a := refAlpha[y*ref.Width+x]
+				if a == 0.0 || x+dx < 0 || x+dx >= img.Width {
+					continue
+				}
+				d := imgLuma[(y+dy)*img.Width+x+dx] - refLuma[y*ref.Width+x]
+				sum += a * d * d
+				weight += a
+			}
+		}
+		if 2*weight < total {
+			scores[k] = math.Inf(1)
+		} else {
+			scores[k] = sum / weight
+		}
+	})
+
+	best := image.Point{}
+	bestScore := scores[maxShift*side+maxShift]
+	for k, score := range scores {
+		offset := image.Point{k%side - maxShift, k/side - maxShift}
+		if score < bestScore || (score == bestScore && manhattan(offset) < manhattan(best)) {
+			best, bestScore = offset, score
+		}
+	}
+	return best
+}
+
+// manhattan returns the Manhattan length of the offset
+func manhattan(p image.Point) int {
+	if p.X < 0 {
+		p.X = -p.X
+	}
+	if p.Y < 0 {
+		p.Y = -p.Y
+	}
+	return p.X + p.Y
+}
+
+// shiftAlpha returns an image whose alpha channel is the alpha channel of ref moved by offset.
+// Uncovered pixels are opaque
+func shiftAlpha(ref *TaggedImage, offset image.Point) *image.Alpha16 {
+	b := ref.Image.Bounds()
+	mask := image.NewAlpha16(image.Rect(0, 0, ref.Width, ref.Height))
+	for y := 0; y < ref.Height; y++ {
+		for x := 0; x < ref.Width; x++ {
+			a := uint32(0xFFFF)
+			sx, sy := x-offset.X, y-offset.Y
+			if sx >= 0 && sx < ref.Width && sy >= 0 && sy < ref.Height {
+				_, _, _, a = ref.Image.At(b.Min.X+sx, b.Min.Y+sy).RGBA()
+			}
+			mask.SetAlpha16(x, y, color.Alpha16{uint16(a)})
+		}
+	}
+	return mask
+}
@@ -0,0 +1,57 @@
+package v1
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestRemask(t *testing.T) {
+	// screen renders a white square on gray with a red area (e.g. a clock) starting at clockX
+	screen := func(shift image.Point, clockX int) *image.NRGBA {
+		img := image.NewNRGBA(image.Rect(0, 0, 32, 32))
+		draw.Draw(img, img.Bounds(), image.NewUniform(color.NRGBA{0x80, 0x80, 0x80, 0xFF}), image.Point{}, draw.Src)
+		draw.Draw(img, image.Rect(8, 8, 16, 16).Add(shift), image.NewUniform(color.NRGBA{0xFF, 0xFF, 0xFF, 0xFF}), image.Point{}, draw.Src)
+		draw.Draw(img, image.Rect(clockX, 0, clockX+8, 32), image.NewUniform(red), image.Point{}, draw.Src)
+		return img
+	}
+
+	// the reference image masks the clock
+	refImg := screen(image.Point{}, 20)
+	draw.Draw(refImg, image.Rect(20, 0, 28, 32), image.Transparent, image.Point{}, draw.Src)
+	var ref, img TaggedImage
+	ref.FromImage(refImg, "ref")
+
+	img.FromImage(screen(image.Point{}, 20), "new")
+	offset, err := img.Remask(&ref, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset != (image.Point{}) {
+		t.Fatalf("mask must not be realigned; got offset %v", offset)
+	}
+	if _, _, _, a := img.Image.At(21, 5).RGBA(); a != 0 {
+		t.Fatalf("masked area must be transparent; got alpha %d", a)
+	}
+
+	img.FromImage(screen(image.Point{2, 1}, 22), "shifted")
+	offset, err = img.Remask(&ref, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset != (image.Point{2, 1}) {
+		t.Fatalf("expected offset (2,1); got %v", offset)
+	}
+	if _, _, _, a := img.Image.At(21, 5).RGBA(); a != 0xFFFF {
+		t.Fatalf("area left of the moved mask must be opaque; got alpha %d", a)
+	}
+	if _, _, _, a := img.Image.At(29, 5).RGBA(); a != 0 {
+		t.Fatalf("moved mask must be transparent; got alpha %d", a)
+	}
+
+	img.FromImage(image.NewNRGBA(image.Rect(0, 0, 16, 16)), "small")
+	if _, err := img.Remask(&ref, 4); err == nil {
+		t.Fatal("images with different dimensions must be rejected")
+	}
+}
@@ -0,0 +1,141 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// State is a known screen state of a StateSequence
+type State struct {
+	// Name identifies the state in the timeline
+	Name string
+	// Refs lists the locations of reference images. The state is reached if any of them matches
+	Refs []string
+	// Mask is the location of an image whose alpha channel is applied to every reference image
+	// (see TaggedImage.ApplyMask). Empty means no mask
+	Mask string
+	// Threshold is the maximum Score for the state to be reached. Nil means Config.Threshold
+	Threshold *float64
+	// Timeout is the maximum duration between reaching the previous state (or the start) and this state.
+	// Zero means Config.Deadline
+	Timeout time.Duration
+}
+
+// StateSequence declares states a system goes through in order
+// (e.g. GRUB menu → boot options → bootsplash → login)
+type StateSequence struct {
+	// Source is the location to capture screenshots from, e.g. 'vnc://localhost:5900'
+	Source string
+	// Interval is the duration between two captures. Zero means Config.Interval
+	Interval time.Duration
+	// States lists the expected states in order
+	States []State
+}
+
+// FromFile reads a state sequence from a JSON or (if the file extension is '.yaml' or '.yml') YAML file.
+// Relative filepaths of reference images, masks and the source are relative to the directory of the file.
+// Unknown keys are rejected.
+// Durations are given as duration specifiers like '30s'. Example:
+//
+//	source: vnc://localhost:5900
+//	interval: 1s
+//	states:
+//	  - name: grub
+//	    refs: [grub_menu.png]
+//	    timeout: 30s
+//	  - name: bootsplash
+//	    refs: [bootsplash_23sec.png, bootsplash_30sec.png]
+//	    mask: bootsplash_mask.png
+//	    threshold: 0.05
+//	    timeout: 2m
+func (s *StateSequence) FromFile(fp string) error {
+	type fileState struct {
+		Name      string   `json:"name" yaml:"name"`
+		Refs      []string `json:"refs" yaml:"refs"`
+		Mask      string   `json:"mask,omitempty" yaml:"mask,omitempty"`
+		Threshold *float64 `json:"threshold,omitempty" yaml:"threshold,omitempty"`
+		Timeout   string   `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	}
+	type fileSequence struct {
+		Source   string      `json:"source,omitempty" yaml:"source,omitempty"`
+		Interval string      `json:"interval,omitempty" yaml:"interval,omitempty"`
+		States   []fileState `json:"states" yaml:"states"`
+	}
+
+	var spec fileSequence
+	data, err := os.ReadFile(fp)
+	if err != nil {
+		return err
+	}
+	// unknown keys are rejected, so typos do not silently fall back to defaults
+	switch strings.ToLower(filepath.Ext(fp)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(data, &spec)
+	default:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&spec)
+	}
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(fp)
+	seq := StateSequence{Source: resolveLocation(dir, spec.Source)}
+	if spec.Interval != "" {
+		seq.Interval, err = parseDurationSpecifier(spec.Interval)
+		if err != nil {
+			return err
+		}
+	}
+	if len(spec.States) == 0 {
+		return fmt.Errorf(`state sequence '%s' declares no states`, fp)
+	}
+	for k, st := range spec.States {
+		if st.Name == "" {
+			st.Name = fmt.Sprintf("state %d", k+1)
+		}
+		if len(st.Refs) == 0 {
+			return fmt.Errorf(`state '%s' declares no reference images`, st.Name)
+		}
+		if !validThreshold(st.Threshold) {
+			return fmt.Errorf("threshold of state '%s' must be between 0 and 1; got '%g'", st.Name, *st.Threshold)
+		}
+		state := State{Name: st.Name, Mask: resolveLocation(dir, st.Mask), Threshold: st.Threshold}
+		for _, ref := range st.Refs {
+			state.Refs = append(state.Refs, resolveLocation(dir, ref))
+		}
+		if st.Timeout != "" {
+			state.Timeout, err = parseDurationSpecifier(st.Timeout)
+			if err != nil {
+				return err
+			}
+		}
+		seq.States = append(seq.States, state)
+	}
+
+	*s = seq
+	return nil
+}
+
+// resolveLocation makes a relative filepath in location relative to dir.
+// URIs, absolute filepaths and "-" are returned unmodified
+func resolveLocation(dir, location string) string {
+	if location == "" || location == "-" || strings.Contains(location, "://") {
+		return location
+	}
+	if strings.HasPrefix(location, RAW_LOCATION_PREFIX) {
+		return RAW_LOCATION_PREFIX + resolveLocation(dir, strings.TrimPrefix(location, RAW_LOCATION_PREFIX))
+	}
+	if filepath.IsAbs(location) {
+		return location
+	}
+	return filepath.Join(dir, location)
+}
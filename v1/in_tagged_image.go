@@ -1,11 +1,25 @@
 package v1
 
 import (
+	"bytes"
 	"fmt"
 	"image"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 )
 
+// ImageFormats lists the names of all supported image file formats
+var ImageFormats = []string{"png", "jpeg", "gif", "bmp", "tiff", "webp", "pbm", "pgm", "ppm"}
+
+// ImageExtensions lists the file extensions of all supported image file formats
+var ImageExtensions = []string{".png", ".apng", ".jpg", ".jpeg", ".gif", ".bmp", ".tif", ".tiff", ".webp", ".pbm", ".pgm", ".ppm", ".pnm"}
+
+// pngSignature is the magic number at the beginning of every PNG file
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
 // TaggedImage represents an image with explicit width, height, format and source values
 type TaggedImage struct {
 	// Image is an in-memory Go image.Image instance
@@ -18,10 +32,42 @@ type TaggedImage struct {
 	MinX int
 	// MinY defines the smallest Y coordinate to start comparison with
 	MinY int
-	// Format is the file format as returned by Go's image.Decode
+	// Format is the file format as returned by Go's image.Decode (one of ImageFormats)
 	Format string
+	// BitDepth gives the number of bits per channel (or per palette index) of the source image.
+	// Zero means unknown
+	BitDepth int
 	// Source is a simple description for the source of this image (for example, its filepath)
 	Source string
+	// Frames lists all frames of an animated image (GIF, APNG), each composited onto the full canvas.
+	// Image is the first frame. Frames is nil for still images and until DecodeFrames is called
+	Frames []TaggedImage
+	// Delay gives the display duration of a frame in Frames
+	Delay time.Duration
+	// Orientation is the EXIF orientation (1 to 8) of the source image. Zero means unknown
+	Orientation int
+	// ColorProfile is the description of the ICC profile embedded in the source image.
+	// Empty means none, i.e. sRGB is assumed
+	ColorProfile string
+	// Transforms lists the transformations applied to Image after decoding
+	// (e.g. 'orientation:rotate-90' or 'icc:Display P3->sRGB')
+	Transforms []string
+
+	// location is the location the image was read from, used to re-acquire the image
+	location string
+	// encoded is the data of a possibly animated image (GIF, PNG) until DecodeFrames is called
+	encoded []byte
+}
+
+// isImageFile returns true if the extension of the filepath is one of ImageExtensions
+func isImageFile(fp string) bool {
+	ext := strings.ToLower(filepath.Ext(fp))
+	for _, e := range ImageExtensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
 }
 
 // FromFilepath reads an image from the given filepath
@@ -32,29 +78,89 @@ func (i *TaggedImage) FromFilepath(fp string) error {
 		return err
 	}
 	defer reader.Close()
-	decoded, format, err := image.Decode(reader)
+	return i.FromReader(reader, fp)
+}
+
+// FromReader decodes an image read from r and fills TaggedImage with its data.
+// source is a simple description for the origin of the data
+func (i *TaggedImage) FromReader(r io.Reader, source string) error {
+	// metadata and frames are read from the data, so keep it in memory
+	data, err := io.ReadAll(r)
 	if err != nil {
 		return err
 	}
+	decoded, format, err := image.Decode(bytes.NewReader(data))
+	if err == image.ErrFormat {
+		return fmt.Errorf("unknown image format of '%s'; supported formats: %s", source, strings.Join(ImageFormats, ", "))
+	} else if err != nil {
+		return err
+	}
 
-	i.Image = decoded
-	i.Width = decoded.Bounds().Max.X - decoded.Bounds().Min.X
-	i.Height = decoded.Bounds().Max.Y - decoded.Bounds().Min.Y
-	i.MinX = decoded.Bounds().Min.X
-	i.MinX = decoded.Bounds().Min.Y
+	i.FromImage(decoded, source)
 	i.Format = format
-	i.Source = fp
+	i.BitDepth = bitDepth(decoded, format, data)
+	metadata := readMetadata(data)
+	i.Orientation = metadata.orientation
+	i.ColorProfile = metadata.profile
+	if format == "gif" || format == "png" {
+		i.encoded = data
+	}
 
 	return nil
 }
 
+// FromBytes decodes an encoded image in memory and fills TaggedImage with its data
+func (i *TaggedImage) FromBytes(data []byte) error {
+	return i.FromReader(bytes.NewReader(data), `<memory>`)
+}
+
+// FromImage fills TaggedImage with an already decoded image.
+// The comparison area is the entire image
+func (i *TaggedImage) FromImage(img image.Image, source string) {
+	i.Image = img
+	i.Width = img.Bounds().Max.X - img.Bounds().Min.X
+	i.Height = img.Bounds().Max.Y - img.Bounds().Min.Y
+	i.MinX = img.Bounds().Min.X
+	i.MinY = img.Bounds().Min.Y
+	i.Format = ""
+	i.BitDepth = bitDepth(img, "", nil)
+	i.Source = source
+	i.Frames = nil
+	i.encoded = nil
+	i.Delay = 0
+	i.Orientation = 0
+	i.ColorProfile = ""
+	i.Transforms = nil
+	i.location = ""
+}
+
 // String returns the human-readable representation of TaggedImage
 func (i *TaggedImage) String() string {
-	tmpl := `{Width: %d, Height: %d, MinX: %d, MinY: %d, Format: '%s', Source: '%s', Image: %s}`
+	tmpl := `{Width: %d, Height: %d, MinX: %d, MinY: %d, Format: '%s', BitDepth: %d, Source: '%s', Frames: %d, Transforms: %v, Image: %s}`
 	if i.Image == nil {
-		return fmt.Sprintf(tmpl, i.Width, i.Height, i.MinX, i.MinY, i.Format, i.Source, `nil`)
+		return fmt.Sprintf(tmpl, i.Width, i.Height, i.MinX, i.MinY, i.Format, i.BitDepth, i.Source, len(i.Frames), i.Transforms, `nil`)
 	} else {
-		return fmt.Sprintf(tmpl, i.Width, i.Height, i.MinX, i.MinY, i.Format, i.Source, `<ready>`)
+		return fmt.Sprintf(tmpl, i.Width, i.Height, i.MinX, i.MinY, i.Format, i.BitDepth, i.Source, len(i.Frames), i.Transforms, `<ready>`)
+
+	}
+}
+
+// bitDepth determines the bit depth of the source of a decoded image.
+// For PNG files, the bit depth is read from the IHDR chunk in `header`.
+// Otherwise the bit depth is derived from the type of the decoded image.
+func bitDepth(img image.Image, format string, header []byte) int {
+	if len(header) >= 25 && bytes.HasPrefix(header, pngSignature) {
+		return int(header[24])
+	}
+	if format == "pbm" {
+		return 1
+	}
 
+	switch img.(type) {
+	case *image.RGBA64, *image.NRGBA64, *image.Gray16, *image.Alpha16:
+		return 16
+	case *image.RGBA, *image.NRGBA, *image.Gray, *image.Alpha, *image.Paletted, *image.YCbCr, *image.NYCbCrA, *image.CMYK:
+		return 8
 	}
+	return 0
 }
@@ -0,0 +1,316 @@
+package v1
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+)
+
+// writePNG stores img as PNG file in dir and returns its filepath
+func writePNG(t *testing.T, dir, name string, img image.Image) string {
+	fp := filepath.Join(dir, name)
+	fd, err := os.Create(fp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	if err := png.Encode(fd, img); err != nil {
+		t.Fatal(err)
+	}
+	return fp
+}
+
+// convertImage draws img onto dst and returns dst
+func convertImage(dst draw.Image, img image.Image) image.Image {
+	draw.Draw(dst, dst.Bounds(), img, img.Bounds().Min, draw.Src)
+	return dst
+}
+
+func TestBitDepths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scmp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var src TaggedImage
+	if err := src.FromFilepath(FILES["grml_kB"]); err != nil {
+		t.Fatal(err)
+	}
+	bounds := src.Image.Bounds()
+	gray := convertImage(image.NewGray(bounds), src.Image)
+
+	images := []struct {
+		name     string
+		img      image.Image
+		bitDepth int
+	}{
+		{"paletted.png", src.Image, 4}, // at most 16 colors are encoded with 4 bits
+		{"rgba.png", convertImage(image.NewRGBA(bounds), src.Image), 8},
+		{"nrgba64.png", convertImage(image.NewNRGBA64(bounds), src.Image), 16},
+		{"gray.png", gray, 8},
+		{"gray16.png", convertImage(image.NewGray16(bounds), gray), 16},
+	}
+
+	scores := make(map[string]float64)
+	for _, entry := range images {
+		s := defaultConfig()
+		var r Result
+		if err := s.BaseImg.FromFilepath(writePNG(t, dir, entry.name, entry.img)); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.RefImg.FromFilepath(FILES["grml_MB"]); err != nil {
+			t.Fatal(err)
+		}
+		if err := Compare(&s, &r); err != nil {
+			t.Fatal(err)
+		}
+		if r.BaseBitDepth != entry.bitDepth {
+			t.Fatalf("expected bit depth %d for %s; got %d", entry.bitDepth, entry.name, r.BaseBitDepth)
+		}
+		scores[entry.name] = r.Score
+	}
+
+	if scores["paletted.png"] != scores["rgba.png"] || scores["paletted.png"] != scores["nrgba64.png"] {
+		t.Fatalf("8-bit, 16-bit and paletted PNGs must yield the same score; got %v", scores)
+	}
+	if scores["gray.png"] != scores["gray16.png"] {
+		t.Fatalf("8-bit and 16-bit grayscale PNGs must yield the same score; got %v", scores)
+	}
+}
+
+func TestBitDepthOfDecodedImages(t *testing.T) {
+	bounds := image.Rect(0, 0, 1, 1)
+	expected := map[int]image.Image{
+		8:  image.NewRGBA(bounds),
+		16: image.NewRGBA64(bounds),
+		0:  image.NewUniform(color.Black),
+	}
+	for depth, img := range expected {
+		if bitDepth(img, "", nil) != depth {
+			t.Fatalf("expected bit depth %d for %T; got %d", depth, img, bitDepth(img, "", nil))
+		}
+	}
+}
+
+func TestPPM(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scmp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := defaultConfig()
+	var r Result
+	if err := s.RefImg.FromFilepath(FILES["grmlf_bs_23"]); err != nil {
+		t.Fatal(err)
+	}
+
+	// store reference image as PPM (P6) like QEMU's screendump does
+	ppm := bytes.NewBufferString(fmt.Sprintf("P6\n%d %d\n255\n", s.RefImg.Width, s.RefImg.Height))
+	for y := 0; y < s.RefImg.Height; y++ {
+		for x := 0; x < s.RefImg.Width; x++ {
+			c := color.NRGBAModel.Convert(s.RefImg.Image.At(x, y)).(color.NRGBA)
+			ppm.Write([]byte{c.R, c.G, c.B})
+		}
+	}
+	fp := filepath.Join(dir, "screendump.ppm")
+	if err := ioutil.WriteFile(fp, ppm.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.BaseImg.FromFilepath(fp); err != nil {
+		t.Fatal(err)
+	}
+	if s.BaseImg.Format != "ppm" || s.BaseImg.BitDepth != 8 {
+		t.Fatalf("expected 8-bit ppm; got %d-bit %s", s.BaseImg.BitDepth, s.BaseImg.Format)
+	}
+	if err := Compare(&s, &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Score != 0.0 {
+		t.Fatalf("PPM image must match its PNG source; got difference of %f", r.Score)
+	}
+}
+
+func TestImageFormats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scmp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	encode := func(name string, src string, enc func(io.Writer, image.Image) error) string {
+		var img TaggedImage
+		if err := img.FromFilepath(src); err != nil {
+			t.Fatal(err)
+		}
+		fp := filepath.Join(dir, name)
+		fd, err := os.Create(fp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer fd.Close()
+		if err := enc(fd, img.Image); err != nil {
+			t.Fatal(err)
+		}
+		return fp
+	}
+	encodeGIF := func(w io.Writer, img image.Image) error {
+		return gif.Encode(w, img, nil)
+	}
+	encodeTIFF := func(w io.Writer, img image.Image) error {
+		return tiff.Encode(w, img, nil)
+	}
+
+	// GIF only supports 256 colors, hence a paletted PNG is used
+	files := []struct {
+		format string
+		path   string
+		ref    string
+	}{
+		{"gif", encode("grml.gif", FILES["grml_kB"], encodeGIF), FILES["grml_kB"]},
+		{"bmp", encode("bootsplash.bmp", FILES["grmlf_bs_23"], bmp.Encode), FILES["grmlf_bs_23"]},
+		{"tiff", encode("bootsplash.tiff", FILES["grmlf_bs_23"], encodeTIFF), FILES["grmlf_bs_23"]},
+		{"webp", strings.TrimSuffix(FILES["grmlf_bs_23"], ".png") + ".webp", FILES["grmlf_bs_23"]},
+	}
+
+	for _, file := range files {
+		s := defaultConfig()
+		var r Result
+		if err := s.BaseImg.FromFilepath(file.path); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.RefImg.FromFilepath(file.ref); err != nil {
+			t.Fatal(err)
+		}
+		if err := Compare(&s, &r); err != nil {
+			t.Fatal(err)
+		}
+		if r.BaseFormat != file.format || r.RefFormat != "png" {
+			t.Fatalf("expected formats %s and png; got %s and %s", file.format, r.BaseFormat, r.RefFormat)
+		}
+		if r.Score != 0.0 {
+			t.Fatalf("%s image must match its PNG source; got difference of %f", file.format, r.Score)
+		}
+	}
+}
+
+func TestUnknownImageFormat(t *testing.T) {
+	var img TaggedImage
+	err := img.FromFilepath(filepath.Join("../tests", "results_table.adoc"))
+	if err == nil {
+		t.Fatalf("decoding a text file must fail")
+	}
+	if !strings.Contains(err.Error(), strings.Join(ImageFormats, ", ")) {
+		t.Fatalf("error must list supported formats; got '%s'", err)
+	}
+}
+
+func TestReadersAndBytes(t *testing.T) {
+	data, err := ioutil.ReadFile(FILES["g"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := defaultConfig()
+	var r Result
+	if err := s.BaseImg.FromBytes(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RefImg.FromReader(bytes.NewReader(data), "reader"); err != nil {
+		t.Fatal(err)
+	}
+	if s.BaseImg.Format != "png" || s.RefImg.Source != "reader" {
+		t.Fatalf("unexpected tagged images %s and %s", s.BaseImg.String(), s.RefImg.String())
+	}
+	if err := Compare(&s, &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Score != 0.0 {
+		t.Fatalf("Same image must return difference %f; got %f", 0.0, r.Score)
+	}
+
+	// compare a sub-image with a sub-image at a different offset
+	sub := s.BaseImg.Image.(interface {
+		SubImage(image.Rectangle) image.Image
+	})
+	s.BaseImg.FromImage(sub.SubImage(image.Rect(10, 20, 110, 70)), "sub1")
+	s.RefImg.FromImage(sub.SubImage(image.Rect(10, 20, 110, 70)), "sub2")
+	if s.BaseImg.MinX != 10 || s.BaseImg.MinY != 20 || s.BaseImg.Width != 100 || s.BaseImg.Height != 50 {
+		t.Fatalf("unexpected tagged image %s", s.BaseImg.String())
+	}
+	if err := Compare(&s, &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Score != 0.0 {
+		t.Fatalf("Same sub-image must return difference %f; got %f", 0.0, r.Score)
+	}
+}
+
+func TestRawFramebuffer(t *testing.T) {
+	var ref TaggedImage
+	if err := ref.FromFilepath(FILES["grmlf_bs_23"]); err != nil {
+		t.Fatal(err)
+	}
+
+	// XRGB8888 with 8 bytes padding per row, stored as B, G, R, X
+	f := RawFormat{PixelFormat: "XRGB8888", Width: ref.Width, Height: ref.Height, Stride: 4*ref.Width + 8}
+	var dump bytes.Buffer
+	for y := 0; y < ref.Height; y++ {
+		for x := 0; x < ref.Width; x++ {
+			c := color.NRGBAModel.Convert(ref.Image.At(x, y)).(color.NRGBA)
+			dump.Write([]byte{c.B, c.G, c.R, 0})
+		}
+		dump.Write(make([]byte, 8))
+	}
+
+	s := defaultConfig()
+	var r Result
+	s.RefImg = ref
+	if err := s.BaseImg.FromRawFramebuffer(&dump, f, "fb0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Compare(&s, &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Score != 0.0 || r.BaseFormat != "raw" {
+		t.Fatalf("raw framebuffer must match its PNG source; got difference of %f", r.Score)
+	}
+
+	// RGB565: white, pure red and pure blue
+	var img TaggedImage
+	f = RawFormat{PixelFormat: "RGB565", Width: 3, Height: 1}
+	if err := img.FromRawFramebuffer(bytes.NewReader([]byte{0xFF, 0xFF, 0x00, 0xF8, 0x1F, 0x00}), f, "rgb565"); err != nil {
+		t.Fatal(err)
+	}
+	expected := []color.NRGBA{{0xFF, 0xFF, 0xFF, 0xFF}, {0xFF, 0, 0, 0xFF}, {0, 0, 0xFF, 0xFF}}
+	for x, c := range expected {
+		if img.Image.At(x, 0) != c {
+			t.Fatalf("expected %v at x=%d; got %v", c, x, img.Image.At(x, 0))
+		}
+	}
+
+	if err := img.FromRawFramebuffer(bytes.NewReader([]byte{0xFF}), f, "truncated"); err == nil {
+		t.Fatalf("truncated raw framebuffer must be rejected")
+	}
+	if _, err := parseRawFormat("YUYV", "3x1", ""); err == nil {
+		t.Fatalf("unknown raw pixel format must be rejected")
+	}
+	if f, err := parseRawFormat("bgra8888", "1024x768", "4096"); err != nil || f.Width != 1024 || f.Stride != 4096 {
+		t.Fatalf("unexpected raw format %s (%v)", f, err)
+	}
+}
@@ -0,0 +1,231 @@
+package v1
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+)
+
+// orientationTransforms names the transformation restoring the upright image for each EXIF orientation
+var orientationTransforms = [9]string{"", "", "flip-horizontal", "rotate-180", "flip-vertical", "transpose", "rotate-90", "transverse", "rotate-270"}
+
+// rgbColorSpace defines a RGB color space by its chromaticities
+type rgbColorSpace struct {
+	// primaries gives the xy chromaticities of red, green and blue
+	primaries [3][2]float64
+	// white gives the xy chromaticity of the white point
+	white [2]float64
+	// gamma is the exponent of the transfer function. Zero means the sRGB transfer function
+	gamma float64
+}
+
+var (
+	srgbColorSpace      = rgbColorSpace{[3][2]float64{{0.64, 0.33}, {0.30, 0.60}, {0.15, 0.06}}, [2]float64{0.3127, 0.3290}, 0}
+	displayP3ColorSpace = rgbColorSpace{[3][2]float64{{0.680, 0.320}, {0.265, 0.690}, {0.150, 0.060}}, [2]float64{0.3127, 0.3290}, 0}
+	adobeRGBColorSpace  = rgbColorSpace{[3][2]float64{{0.64, 0.33}, {0.21, 0.71}, {0.15, 0.06}}, [2]float64{0.3127, 0.3290}, 563.0 / 256.0}
+)
+
+// colorProfiles maps (lowercase) parts of ICC profile descriptions to color spaces
+// which can be converted to sRGB
+var colorProfiles = map[string]rgbColorSpace{
+	"display p3": displayP3ColorSpace,
+	"adobe rgb":  adobeRGBColorSpace,
+}
+
+// ApplyOrientation rotates and flips Image and all Frames according to its EXIF Orientation,
+// so the image is upright. The applied transformation is appended to Transforms
+func (i *TaggedImage) ApplyOrientation() {
+	if i.Orientation <= 1 || i.Orientation > 8 || i.Image == nil {
+		return
+	}
+
+	src := i.Image
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if i.Orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA64(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch i.Orientation {
+			case 2:
+				sx, sy = w-1-x, y
+			case 3:
+				sx, sy = w-1-x, h-1-y
+			case 4:
+				sx, sy = x, h-1-y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, h-1-x
+			case 7:
+				sx, sy = w-1-y, h-1-x
+			case 8:
+				sx, sy = w-1-y, x
+			}
+			dst.Set(x, y, src.At(b.Min.X+sx, b.Min.Y+sy))
+		}
+	}
+
+	for k := range i.Frames {
+		i.Frames[k].Orientation = i.Orientation
+		i.Frames[k].ApplyOrientation()
+	}
+	i.Transforms = append(i.Transforms, "orientation:"+orientationTransforms[i.Orientation])
+	i.Image = dst
+	i.Width, i.Height = dw, dh
+	i.MinX, i.MinY = 0, 0
+	i.Orientation = 1
+}
+
+// ConvertToSRGB converts Image and all Frames from the color space of its embedded ICC profile
+// (Display P3 or Adobe RGB, see ColorProfile) to sRGB. Images with other or no profiles
+// are not modified. The applied conversion is appended to Transforms
+func (i *TaggedImage) ConvertToSRGB() {
+	if i.Image == nil {
+		return
+	}
+	var src rgbColorSpace
+	found := false
+	for name, cs := range colorProfiles {
+		if strings.Contains(strings.ToLower(i.ColorProfile), name) {
+			src, found = cs, true
+		}
+	}
+	if !found {
+		return
+	}
+
+	m := mulMatrix(invertMatrix(srgbColorSpace.toXYZ()), src.toXYZ())
+	b := i.Image.Bounds()
+	dst := image.NewNRGBA64(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBA64Model.Convert(i.Image.At(x, y)).(color.NRGBA64)
+			r := src.decode(float64(c.R) / 65535)
+			g := src.decode(float64(c.G) / 65535)
+			bl := src.decode(float64(c.B) / 65535)
+			dst.SetNRGBA64(x, y, color.NRGBA64{
+				srgbColorSpace.encode(m[0][0]*r + m[0][1]*g + m[0][2]*bl),
+				srgbColorSpace.encode(m[1][0]*r + m[1][1]*g + m[1][2]*bl),
+				srgbColorSpace.encode(m[2][0]*r + m[2][1]*g + m[2][2]*bl),
+				c.A,
+			})
+		}
+	}
+
+	for k := range i.Frames {
+		i.Frames[k].ColorProfile = i.ColorProfile
+		i.Frames[k].ConvertToSRGB()
+	}
+	i.Transforms = append(i.Transforms, "icc:"+i.ColorProfile+"->sRGB")
+	i.Image = dst
+	i.ColorProfile = ""
+}
+
+// decode converts a gamma-encoded value between 0 and 1 to linear light
+func (cs rgbColorSpace) decode(v float64) float64 {
+	if cs.gamma != 0 {
+		return math.Pow(v, cs.gamma)
+	}
+	return toLinear(v*65535) / 65535
+}
+
+// encode converts linear light to a gamma-encoded 16-bit value
+func (cs rgbColorSpace) encode(v float64) uint16 {
+	v = math.Max(0, math.Min(1, v))
+	if cs.gamma != 0 {
+		v = math.Pow(v, 1/cs.gamma)
+	} else if v <= 0.0031308 {
+		v *= 12.92
+	} else {
+		v = 1.055*math.Pow(v, 1/2.4) - 0.055
+	}
+	return uint16(math.Round(v * 65535))
+}
+
+// toXYZ returns the matrix converting linear RGB values of the color space to CIE XYZ
+func (cs rgbColorSpace) toXYZ() [3][3]float64 {
+	xyz := func(xy [2]float64) [3]float64 {
+		return [3]float64{xy[0] / xy[1], 1, (1 - xy[0] - xy[1]) / xy[1]}
+	}
+
+	var p [3][3]float64
+	for c := 0; c < 3; c++ {
+		col := xyz(cs.primaries[c])
+		for row := 0; row < 3; row++ {
+			p[row][c] = col[row]
+		}
+	}
+	w := xyz(cs.white)
+	inv := invertMatrix(p)
+	for c := 0; c < 3; c++ {
+		s := inv[c][0]*w[0] + inv[c][1]*w[1] + inv[c][2]*w[2]
+		for row := 0; row < 3; row++ {
+			p[row][c] *= s
+		}
+	}
+	return p
+}
+
+// ApplyMask multiplies the alpha channel of Image with the alpha channel of the given mask,
+// so areas transparent in the mask are ignored (for alpha mode "ref-mask").
+// The dimensions of the mask must correspond. The applied mask is appended to Transforms
+func (i *TaggedImage) ApplyMask(mask *TaggedImage) error {
+	if i.Width != mask.Width || i.Height != mask.Height {
+		return fmt.Errorf("mask dimensions do not correspond; got %d×%d (image) and %d×%d (mask '%s')", i.Width, i.Height, mask.Width, mask.Height, mask.Source)
+	}
+
+	src := i.Image.Bounds()
+	mb := mask.Image.Bounds()
+	dst := image.NewNRGBA64(image.Rect(0, 0, i.Width, i.Height))
+	for y := 0; y < i.Height; y++ {
+		for x := 0; x < i.Width; x++ {
+			c := color.NRGBA64Model.Convert(i.Image.At(src.Min.X+x, src.Min.Y+y)).(color.NRGBA64)
+			_, _, _, a := mask.Image.At(mb.Min.X+x, mb.Min.Y+y).RGBA()
+			c.A = uint16(uint32(c.A) * a / 0xFFFF)
+			dst.SetNRGBA64(x, y, c)
+		}
+	}
+
+	i.Transforms = append(i.Transforms, "mask:"+mask.Source)
+	i.Image = dst
+	i.MinX, i.MinY = 0, 0
+	return nil
+}
+
+// mulMatrix returns the product a·b of two 3×3 matrices
+func mulMatrix(a, b [3][3]float64) [3][3]float64 {
+	var m [3][3]float64
+	for row := 0; row < 3; row++ {
+		for col := 0; col < 3; col++ {
+			for k := 0; k < 3; k++ {
+				m[row][col] += a[row][k] * b[k][col]
+			}
+		}
+	}
+	return m
+}
+
+// invertMatrix returns the inverse of a non-singular 3×3 matrix
+func invertMatrix(m [3][3]float64) [3][3]float64 {
+	det := m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+		m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+		m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])
+	var inv [3][3]float64
+	for row := 0; row < 3; row++ {
+		for col := 0; col < 3; col++ {
+			// cofactor of the transposed matrix
+			r1, r2 := (col+1)%3, (col+2)%3
+			c1, c2 := (row+1)%3, (row+2)%3
+			inv[row][col] = (m[r1][c1]*m[r2][c2] - m[r1][c2]*m[r2][c1]) / det
+		}
+	}
+	return inv
+}
@@ -0,0 +1,89 @@
+package v1
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// HTTPTimeout limits the duration of fetching an image via HTTP(S), including the response body
+var HTTPTimeout = 30 * time.Second
+
+// HTTPMaxBytes limits the size of an image fetched via HTTP(S)
+var HTTPMaxBytes int64 = 64 << 20
+
+// HTTP_HEADER_ENV names the environment variable providing additional request headers
+// like 'Authorization: Bearer <token>'. Several headers are separated by newlines
+const HTTP_HEADER_ENV = `SCMP_HTTP_HEADER`
+
+func init() {
+	RegisterScheme("http", func(i *TaggedImage, u *url.URL) error { return i.FromURL(u.String()) })
+	RegisterScheme("https", func(i *TaggedImage, u *url.URL) error { return i.FromURL(u.String()) })
+}
+
+// FromURL fetches the image at the given http:// or https:// URL and fills TaggedImage with its data.
+// Requests are limited by HTTPTimeout and HTTPMaxBytes and carry the headers
+// given in the environment variable HTTP_HEADER_ENV. Credentials in the URL are sent as basic auth.
+func (i *TaggedImage) FromURL(location string) error {
+	u, err := url.Parse(location)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("URL '%s' must use scheme http or https", u.Redacted())
+	}
+	source := u.Redacted()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	if err := addHTTPHeaders(req.Header, os.Getenv(HTTP_HEADER_ENV)); err != nil {
+		return err
+	}
+
+	client := http.Client{Timeout: HTTPTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("cannot fetch '%s': %s", source, resp.Status)
+	}
+	if resp.ContentLength > HTTPMaxBytes {
+		return fmt.Errorf("image '%s' exceeds the size limit of %d bytes", source, HTTPMaxBytes)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, HTTPMaxBytes+1))
+	if err != nil {
+		return fmt.Errorf("cannot fetch '%s': %s", source, err)
+	}
+	if int64(len(data)) > HTTPMaxBytes {
+		return fmt.Errorf("image '%s' exceeds the size limit of %d bytes", source, HTTPMaxBytes)
+	}
+
+	return i.FromReader(bytes.NewReader(data), source)
+}
+
+// addHTTPHeaders adds the newline-separated headers 'Name: value' to header
+func addHTTPHeaders(header http.Header, headers string) error {
+	for _, line := range strings.Split(headers, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return fmt.Errorf("invalid HTTP header '%s' in %s; expected 'Name: value'", line, HTTP_HEADER_ENV)
+		}
+		header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package v1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// imageServer serves the test images under /<name>.png. Requests without the header
+// 'X-Token: secret' are rejected for paths below /private/
+func imageServer(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/"), ".png")
+		if strings.HasPrefix(name, "private/") {
+			if r.Header.Get("X-Token") != "secret" {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			name = strings.TrimPrefix(name, "private/")
+		}
+		fp, ok := FILES[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeFile(w, r, fp)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestFromURL(t *testing.T) {
+	srv := imageServer(t)
+
+	var img, expected TaggedImage
+	if err := img.FromURL(srv.URL + "/g.png"); err != nil {
+		t.Fatal(err)
+	}
+	if err := expected.FromFilepath(FILES["g"]); err != nil {
+		t.Fatal(err)
+	}
+	if img.Width != expected.Width || img.Height != expected.Height || img.Format != "png" || img.Source != srv.URL+"/g.png" {
+		t.Fatalf("unexpected image %s", img.String())
+	}
+
+	if err := img.FromURL(srv.URL + "/missing.png"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("missing image must be reported; got %v", err)
+	}
+	if err := img.FromURL("ftp://localhost/g.png"); err == nil {
+		t.Fatal("unsupported URL scheme must be rejected")
+	}
+}
+
+func TestFromURLSizeLimit(t *testing.T) {
+	srv := imageServer(t)
+	defer func(max int64) { HTTPMaxBytes = max }(HTTPMaxBytes)
+	HTTPMaxBytes = 100
+
+	var img TaggedImage
+	if err := img.FromURL(srv.URL + "/g.png"); err == nil || !strings.Contains(err.Error(), "size limit") {
+		t.Fatalf("image exceeding HTTPMaxBytes must be rejected; got %v", err)
+	}
+}
+
+func TestFromURLHeaders(t *testing.T) {
+	srv := imageServer(t)
+
+	var img TaggedImage
+	t.Setenv(HTTP_HEADER_ENV, "")
+	if err := img.FromLocation(srv.URL + "/private/g.png"); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("request without header must be rejected; got %v", err)
+	}
+
+	t.Setenv(HTTP_HEADER_ENV, "Accept: image/png\nX-Token: secret")
+	if err := img.FromLocation(srv.URL + "/private/g.png"); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(HTTP_HEADER_ENV, "no header")
+	if err := img.FromLocation(srv.URL + "/private/g.png"); err == nil {
+		t.Fatal("invalid header must be rejected")
+	}
+}
+
+func TestURLsInConfig(t *testing.T) {
+	srv := imageServer(t)
+	t.Setenv(`SCMP_BASEIMG`, srv.URL+"/grml_kB.png")
+	t.Setenv(`SCMP_REFIMG`, srv.URL+"/grml_MB.png")
+
+	c := defaultConfig()
+	if _, err := c.FromEnv(2); err != nil {
+		t.Fatal(err)
+	}
+	if c.BaseImg.Source != srv.URL+"/grml_kB.png" || c.RefImg.Source != srv.URL+"/grml_MB.png" {
+		t.Fatalf("images must be fetched from %s; got %s", srv.URL, c.String())
+	}
+}
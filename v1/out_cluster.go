@@ -0,0 +1,78 @@
+package v1
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ClusterFormats lists the output formats supported by ClusterResult.Write
+var ClusterFormats = []string{"text", "csv", "json"}
+
+// Write writes a report mapping every image to its cluster to w in the given format,
+// one of ClusterFormats. Clusters are numbered from 1
+func (r *ClusterResult) Write(w io.Writer, format string) error {
+	switch format {
+	case "text":
+		var b strings.Builder
+		for k, cluster := range r.Clusters {
+			fmt.Fprintf(&b, "cluster %d: %d images, representative %s\n", k+1, len(cluster.Members), cluster.Representative)
+			for _, member := range cluster.Members {
+				fmt.Fprintf(&b, "  %s\n", member)
+			}
+		}
+		_, err := io.WriteString(w, b.String())
+		return err
+
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"image", "cluster", "representative"})
+		for k, cluster := range r.Clusters {
+			for _, member := range cluster.Members {
+				cw.Write([]string{member, strconv.Itoa(k + 1), cluster.Representative})
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+
+	case "json":
+		type jsonCluster struct {
+			Cluster        int      `json:"cluster"`
+			Representative string   `json:"representative"`
+			Members        []string `json:"members"`
+		}
+		type jsonSkipped struct {
+			Location string `json:"location"`
+			Error    string `json:"error"`
+		}
+		type jsonReport struct {
+			Clusters []jsonCluster  `json:"clusters"`
+			Images   map[string]int `json:"images"`
+			Skipped  []jsonSkipped  `json:"skipped"`
+			Runtime  string         `json:"runtime"`
+		}
+
+		report := jsonReport{
+			Clusters: make([]jsonCluster, 0, len(r.Clusters)),
+			Images:   make(map[string]int),
+			Skipped:  make([]jsonSkipped, 0, len(r.Skipped)),
+			Runtime:  r.Runtime.String(),
+		}
+		for k, cluster := range r.Clusters {
+			report.Clusters = append(report.Clusters, jsonCluster{k + 1, cluster.Representative, cluster.Members})
+			for _, member := range cluster.Members {
+				report.Images[member] = k + 1
+			}
+		}
+		for _, s := range r.Skipped {
+			report.Skipped = append(report.Skipped, jsonSkipped{s.Location, s.Err.Error()})
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	return fmt.Errorf("unknown cluster format '%s'; supported formats: %s", format, strings.Join(ClusterFormats, ", "))
+}
@@ -0,0 +1,36 @@
+package v1
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+// DiffImage renders the differences between BaseImg and RefImg like the pixel-by-pixel score
+// in Config.ColorSpace: the base image is shown dimmed in gray and every pixel is tinted red
+// according to its difference weighted by AlphaMode. Pixels masked by the reference image
+// stay gray. The dimensions of the images must correspond
+func DiffImage(c *Config) (*image.NRGBA, error) {
+	if c.BaseImg.Width != c.RefImg.Width || c.BaseImg.Height != c.RefImg.Height {
+		msg := "image dimensions do not correspond; got %d×%d (base) and %d×%d (ref)"
+		return nil, fmt.Errorf(msg, c.BaseImg.Width, c.BaseImg.Height, c.RefImg.Width, c.RefImg.Height)
+	}
+
+	diff := image.NewNRGBA(image.Rect(0, 0, c.BaseImg.Width, c.BaseImg.Height))
+	for y := 0; y < c.BaseImg.Height; y++ {
+		for x := 0; x < c.BaseImg.Width; x++ {
+			r1, g1, b1, a1, r2, g2, b2, a2, weight := c.samplePixels(x, y)
+			d := c.alphaDistance(c.pixelDistance(c.ColorSpace, r1, g1, b1, r2, g2, b2), a1, a2) * weight
+
+			// dimmed luma of the base image (BT.601 weights)
+			gray := (0.299*r1 + 0.587*g1 + 0.114*b1) / 65535 * 0.4
+			// small differences are emphasized, so single pixels remain visible
+			tint := math.Min(1.0, d)
+			red := gray + (1.0-gray)*math.Sqrt(tint)
+			other := gray * (1.0 - tint)
+			diff.SetNRGBA(x, y, color.NRGBA{uint8(255 * red), uint8(255 * other), uint8(255 * other), 0xFF})
+		}
+	}
+	return diff, nil
+}
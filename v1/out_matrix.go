@@ -0,0 +1,180 @@
+package v1
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// MatrixFormats lists the output formats supported by MatrixResult.Write
+var MatrixFormats = []string{"adoc", "md", "csv", "json"}
+
+// Write writes the score matrix to w in the given format, one of MatrixFormats.
+// "adoc" and "md" give percentages in tables with numbered rows and columns,
+// "csv" and "json" give scores between 0 and 1. Cells of images with
+// different dimensions are empty in "csv" and null in "json"
+func (r *MatrixResult) Write(w io.Writer, format string) error {
+	switch format {
+	case "adoc":
+		return r.writeAsciiDoc(w)
+	case "md":
+		return r.writeMarkdown(w)
+	case "csv":
+		return r.writeCSV(w)
+	case "json":
+		return r.writeJSON(w)
+	}
+	return fmt.Errorf("unknown matrix format '%s'; supported formats: %s", format, strings.Join(MatrixFormats, ", "))
+}
+
+// matrixLabel returns the label of a table cell
+func matrixLabel(cell MatrixCell) string {
+	switch {
+	case cell.Err != nil:
+		return "!"
+	case cell.Mismatch:
+		return "*"
+	}
+	return fmt.Sprintf("%d %%", int(100*cell.Score))
+}
+
+// matrixLegend returns the legend of the labels of table cells without scores
+func (r *MatrixResult) matrixLegend() string {
+	legend := "\n`*` dimensions mismatch\n"
+	for _, cells := range r.Cells {
+		for _, cell := range cells {
+			if cell.Err != nil {
+				return legend + "\n`!` comparison failed\n"
+			}
+		}
+	}
+	return legend
+}
+
+// writeAsciiDoc writes the score matrix as AsciiDoc table in the layout of tests/results_table.adoc
+func (r *MatrixResult) writeAsciiDoc(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("Score results\n=============\n\n")
+	for k, img := range r.Images {
+		fmt.Fprintf(&b, "%d. `%s`\n", k+1, img)
+	}
+
+	b.WriteString("\n|===\n|     ")
+	for k := range r.Images {
+		fmt.Fprintf(&b, "|%d.  ", k+1)
+	}
+	b.WriteString("\n")
+	for row, cells := range r.Cells {
+		fmt.Fprintf(&b, "|%d.   ", row+1)
+		for _, cell := range cells {
+			if label := matrixLabel(cell); len(label) == 1 {
+				fmt.Fprintf(&b, "|%s     ", label)
+			} else {
+				fmt.Fprintf(&b, "|%s  ", label)
+			}
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("|===\n")
+
+	b.WriteString(r.matrixLegend())
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeMarkdown writes the score matrix as Markdown table
+func (r *MatrixResult) writeMarkdown(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("# Score results\n\n")
+	for k, img := range r.Images {
+		fmt.Fprintf(&b, "%d. `%s`\n", k+1, img)
+	}
+
+	b.WriteString("\n|    ")
+	for k := range r.Images {
+		fmt.Fprintf(&b, "| %d. ", k+1)
+	}
+	b.WriteString("|\n|----")
+	for range r.Images {
+		b.WriteString("|---:")
+	}
+	b.WriteString("|\n")
+	for row, cells := range r.Cells {
+		fmt.Fprintf(&b, "| %d. ", row+1)
+		for _, cell := range cells {
+			fmt.Fprintf(&b, "| %s ", strings.ReplaceAll(matrixLabel(cell), "*", "\\*"))
+		}
+		b.WriteString("|\n")
+	}
+
+	b.WriteString(strings.ReplaceAll(r.matrixLegend(), "`*`", "\\*"))
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeCSV writes the score matrix as CSV with a header row and a label column of image locations
+func (r *MatrixResult) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(append([]string{""}, r.Images...)); err != nil {
+		return err
+	}
+	for row, cells := range r.Cells {
+		record := []string{r.Images[row]}
+		for _, cell := range cells {
+			if cell.Mismatch || cell.Err != nil {
+				record = append(record, "")
+			} else {
+				record = append(record, strconv.FormatFloat(cell.Score, 'f', 6, 64))
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeJSON writes the score matrix as JSON object
+func (r *MatrixResult) writeJSON(w io.Writer) error {
+	type jsonSkipped struct {
+		Location string `json:"location"`
+		Error    string `json:"error"`
+	}
+	type jsonMatrix struct {
+		Images  []string      `json:"images"`
+		Skipped []jsonSkipped `json:"skipped"`
+		Scores  [][]*float64  `json:"scores"`
+		Matches [][]bool      `json:"matches"`
+		Runtime string        `json:"runtime"`
+	}
+
+	matrix := jsonMatrix{
+		Images:  append([]string{}, r.Images...),
+		Skipped: make([]jsonSkipped, 0, len(r.Skipped)),
+		Scores:  make([][]*float64, len(r.Cells)),
+		Matches: make([][]bool, len(r.Cells)),
+		Runtime: r.Runtime.String(),
+	}
+	for _, s := range r.Skipped {
+		matrix.Skipped = append(matrix.Skipped, jsonSkipped{s.Location, s.Err.Error()})
+	}
+	for row, cells := range r.Cells {
+		matrix.Scores[row] = make([]*float64, len(cells))
+		matrix.Matches[row] = make([]bool, len(cells))
+		for col, cell := range cells {
+			if !cell.Mismatch && cell.Err == nil {
+				score := cell.Score
+				matrix.Scores[row][col] = &score
+			}
+			matrix.Matches[row][col] = cell.Match
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(matrix)
+}
@@ -0,0 +1,241 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// PENDING_ACTUAL is the filename of the actual image of a pending change
+	PENDING_ACTUAL = `actual.png`
+	// PENDING_DIFF is the filename of the diff image (see DiffImage) of a pending change
+	PENDING_DIFF = `diff.png`
+	// PENDING_INFO is the filename of the JSON description of a pending change
+	PENDING_INFO = `pending.json`
+)
+
+// PendingChange is a failed comparison recorded by RecordPending for approval.
+// Its directory in the pending directory contains PENDING_ACTUAL, PENDING_DIFF
+// (unless the dimensions differ) and PENDING_INFO
+type PendingChange struct {
+	// Name identifies the change. It is the relative path (with slashes) of its directory
+	Name string `json:"-"`
+	// Dir is the directory of the change
+	Dir string `json:"-"`
+	// Ref is the absolute filepath of the reference image which is replaced on approval
+	Ref string `json:"ref"`
+	// Base is the location the actual image was read from
+	Base string `json:"base"`
+	// Score is the score of the failed comparison
+	Score float64 `json:"score"`
+}
+
+// RecordPending records a failed comparison in the directory Config.Pending, so that
+// Approve can promote the actual image (BaseImg) to the reference image. The name identifies
+// the change and may contain slashes; if it is empty, the filename of the reference image is used.
+// Nothing is recorded if Config.Pending is empty or the images match.
+// The reference image must have been read from a PNG file, because Approve writes PNG data.
+// The name must not leave the pending directory.
+func RecordPending(c *Config, r *Result, name string) error {
+	if c.Pending == "" || r.Match {
+		return nil
+	}
+	ref := c.RefImg.location
+	if ref == "" || ref == "-" || strings.Contains(ref, "://") || strings.HasPrefix(ref, RAW_LOCATION_PREFIX) {
+		return fmt.Errorf(`reference image '%s' cannot be approved; read it from a filepath`, c.RefImg.Source)
+	}
+	if _, _, ok := splitArchiveLocation(ref); ok {
+		return fmt.Errorf(`reference image '%s' cannot be approved; read it from a filepath`, c.RefImg.Source)
+	}
+	if c.RefImg.Format != "png" {
+		return fmt.Errorf(`reference image '%s' cannot be approved; only PNG files are supported, got %s`, c.RefImg.Source, c.RefImg.Format)
+	}
+	ref, err := filepath.Abs(ref)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		name = filepath.Base(ref)
+	}
+
+	rel := filepath.Clean(filepath.FromSlash(name))
+	if filepath.IsAbs(rel) || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf(`name '%s' of pending change must be a relative path inside the pending directory`, name)
+	}
+	dir := filepath.Join(c.Pending, rel)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := WritePNG(filepath.Join(dir, PENDING_ACTUAL), c.BaseImg.Image); err != nil {
+		return err
+	}
+	diffPath := filepath.Join(dir, PENDING_DIFF)
+	if diff, err := DiffImage(c); err == nil {
+		if err := WritePNG(diffPath, diff); err != nil {
+			return err
+		}
+	} else if err := os.Remove(diffPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	info, err := json.MarshalIndent(PendingChange{Ref: ref, Base: c.BaseImg.location, Score: r.Score}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, PENDING_INFO), append(info, '\n'), 0644)
+}
+
+// ListPending returns all changes recorded in the given pending directory sorted by name.
+// A missing pending directory has no changes
+func ListPending(dir string) ([]PendingChange, error) {
+	var changes []PendingChange
+	err := filepath.WalkDir(dir, func(fp string, d fs.DirEntry, err error) error {
+		if os.IsNotExist(err) && fp == dir {
+			return fs.SkipDir
+		} else if err != nil {
+			return err
+		}
+		if d.IsDir() || d.Name() != PENDING_INFO {
+			return nil
+		}
+
+		data, err := os.ReadFile(fp)
+		if err != nil {
+			return err
+		}
+		var change PendingChange
+		if err := json.Unmarshal(data, &change); err != nil {
+			return fmt.Errorf("invalid pending change '%s': %s", fp, err)
+		}
+		change.Dir = filepath.Dir(fp)
+		rel, err := filepath.Rel(dir, change.Dir)
+		if err != nil {
+			return err
+		}
+		change.Name = filepath.ToSlash(rel)
+		changes = append(changes, change)
+		return nil
+	})
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	return changes, err
+}
+
+// Approve promotes the actual images of the named changes in the given pending directory
+// to reference images and removes the changes. If no names are given, all changes are approved.
+// If the previous reference image has transparent areas, its alpha channel is applied to
+// the actual image, so masks are preserved; its dimensions must correspond then.
+// The approved changes are returned, even if an error occurs for a later change.
+func Approve(dir string, names []string) ([]PendingChange, error) {
+	changes, err := ListPending(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) > 0 {
+		byName := make(map[string]PendingChange, len(changes))
+		for _, change := range changes {
+			byName[change.Name] = change
+		}
+		selected := make([]PendingChange, 0, len(names))
+		for _, name := range names {
+			change, ok := byName[strings.Trim(filepath.ToSlash(name), "/")]
+			if !ok {
+				return nil, fmt.Errorf(`no pending change '%s' in '%s'`, name, dir)
+			}
+			selected = append(selected, change)
+		}
+		changes = selected
+	}
+
+	approved := make([]PendingChange, 0, len(changes))
+	for _, change := range changes {
+		if err := approveChange(change); err != nil {
+			return approved, fmt.Errorf(`approving '%s': %s`, change.Name, err)
+		}
+		approved = append(approved, change)
+	}
+	return approved, nil
+}
+
+// approveChange replaces the reference image of a pending change with its actual image
+func approveChange(change PendingChange) error {
+	var actual TaggedImage
+	if err := actual.FromFilepath(filepath.Join(change.Dir, PENDING_ACTUAL)); err != nil {
+		return err
+	}
+	if err := UpdateReference(change.Ref, actual.Image); err != nil {
+		return err
+	}
+	for _, name := range []string{PENDING_ACTUAL, PENDING_DIFF, PENDING_INFO} {
+		if err := os.Remove(filepath.Join(change.Dir, name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	// remove the directory of the change unless it contains other changes
+	os.Remove(change.Dir)
+	return nil
+}
+
+// UpdateReference writes img as PNG file to the reference image at the given filepath.
+// If the previous reference image has transparent areas, its alpha channel is applied to img,
+// so masks are preserved; its dimensions must correspond then. Missing directories are created
+func UpdateReference(ref string, img image.Image) error {
+	var actual, previous TaggedImage
+	actual.FromImage(img, "actual")
+	if err := previous.FromFilepath(ref); err == nil {
+		if !isOpaque(previous.Image) {
+			if err := actual.ApplyMask(&previous); err != nil {
+				return fmt.Errorf(`cannot preserve mask of '%s': %s`, ref, err)
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(ref), 0755); err != nil {
+		return err
+	}
+	return WritePNG(ref, actual.Image)
+}
+
+// isOpaque returns true if no pixel of the image is transparent
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a != 0xFFFF {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// WritePNG encodes the image as PNG file at the given filepath.
+// The file is replaced atomically, so it may be one of the images read
+func WritePNG(fp string, img image.Image) error {
+	tmp := fp + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, fp)
+}
@@ -0,0 +1,167 @@
+package v1
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiffImage(t *testing.T) {
+	c := defaultConfig()
+	base := uniform(green)
+	base.Set(2, 3, blue)
+	c.BaseImg.FromImage(base, "base")
+	c.RefImg.FromImage(uniform(green), "green")
+
+	diff, err := DiffImage(&c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := diff.NRGBAAt(2, 3); d.R < 0xC0 || d.G > 0x10 || d.B > 0x10 {
+		t.Fatalf("different pixel must be tinted red; got %v", d)
+	}
+	if d := diff.NRGBAAt(0, 0); d.R != d.G || d.G != d.B {
+		t.Fatalf("equal pixel must be gray; got %v", d)
+	}
+
+	c.RefImg.FromImage(image.NewRGBA(image.Rect(0, 0, 4, 4)), "small")
+	if _, err := DiffImage(&c); err == nil {
+		t.Fatal("images with different dimensions must be rejected")
+	}
+}
+
+func TestApprove(t *testing.T) {
+	dir := t.TempDir()
+	pending := filepath.Join(dir, "pending")
+
+	// the reference image masks its right half
+	ref := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	draw.Draw(ref, image.Rect(0, 0, 4, 8), image.NewUniform(red), image.Point{}, draw.Src)
+	refPath := writePNG(t, dir, "ref.png", ref)
+	basePath := writePNG(t, dir, "base.png", uniform(blue))
+
+	c := defaultConfig()
+	c.Pending = pending
+	if err := c.LoadImage(&c.BaseImg, basePath); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.LoadImage(&c.RefImg, refPath); err != nil {
+		t.Fatal(err)
+	}
+	var r Result
+	if err := Compare(&c, &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Match {
+		t.Fatal("blue and red must not match")
+	}
+	if err := RecordPending(&c, &r, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := RecordPending(&c, &r, "nested/login"); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := ListPending(pending)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 || changes[0].Name != "nested/login" || changes[1].Name != "ref.png" {
+		t.Fatalf("expected changes 'nested/login' and 'ref.png'; got %v", changes)
+	}
+	if changes[1].Ref != refPath || changes[1].Score != r.Score {
+		t.Fatalf("unexpected change %v", changes[1])
+	}
+	for _, name := range []string{PENDING_ACTUAL, PENDING_DIFF} {
+		if _, err := os.Stat(filepath.Join(changes[1].Dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := Approve(pending, []string{"unknown"}); err == nil {
+		t.Fatal("unknown change must be rejected")
+	}
+	approved, err := Approve(pending, []string{"ref.png"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(approved) != 1 || approved[0].Name != "ref.png" {
+		t.Fatalf("expected approved change 'ref.png'; got %v", approved)
+	}
+
+	var updated TaggedImage
+	if err := updated.FromFilepath(refPath); err != nil {
+		t.Fatal(err)
+	}
+	if got := color.NRGBAModel.Convert(updated.Image.At(1, 1)).(color.NRGBA); got != (color.NRGBA{0, 0, 0xFF, 0xFF}) {
+		t.Fatalf("actual image must become the reference image; got %v", got)
+	}
+	if _, _, _, a := updated.Image.At(6, 1).RGBA(); a != 0 {
+		t.Fatalf("mask of the previous reference image must be preserved; got alpha %d", a)
+	}
+
+	changes, err = ListPending(pending)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Name != "nested/login" {
+		t.Fatalf("approved change must be removed; got %v", changes)
+	}
+
+	c.Pending = ""
+	if err := RecordPending(&c, &r, "other"); err != nil {
+		t.Fatal(err)
+	}
+	if changes, _ := ListPending(pending); len(changes) != 1 {
+		t.Fatal("nothing must be recorded without pending directory")
+	}
+	if changes, err := ListPending(filepath.Join(dir, "missing")); err != nil || len(changes) != 0 {
+		t.Fatalf("missing pending directory must have no changes; got %v, %v", changes, err)
+	}
+}
+
+func TestRecordPendingRejects(t *testing.T) {
+	dir := t.TempDir()
+	c := defaultConfig()
+	c.Pending = filepath.Join(dir, "pending")
+	if err := c.LoadImage(&c.BaseImg, writePNG(t, dir, "base.png", uniform(blue))); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.LoadImage(&c.RefImg, writePNG(t, dir, "ref.png", uniform(red))); err != nil {
+		t.Fatal(err)
+	}
+	r := Result{Score: 1}
+
+	for _, name := range []string{"../escaped", "nested/../../escaped", "..", "/tmp/escaped"} {
+		if err := RecordPending(&c, &r, name); err == nil {
+			t.Fatalf("name '%s' leaving the pending directory must be rejected", name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "escaped")); !os.IsNotExist(err) {
+		t.Fatalf("nothing must be written outside the pending directory; got %v", err)
+	}
+	if err := RecordPending(&c, &r, "nested/../login"); err != nil {
+		t.Fatal(err)
+	}
+
+	// approving writes PNG data, so other formats must not be replaced
+	refJPEG := filepath.Join(dir, "ref.jpg")
+	f, err := os.Create(refJPEG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := jpeg.Encode(f, uniform(red), nil); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if err := c.LoadImage(&c.RefImg, refJPEG); err != nil {
+		t.Fatal(err)
+	}
+	if err := RecordPending(&c, &r, ""); err == nil {
+		t.Fatal("JPEG reference image must be rejected")
+	}
+}
@@ -0,0 +1,51 @@
+package v1
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// WriteJSON writes a summary report of the directory comparison as JSON to w.
+// actualDir and expectedDir are the directories given to CompareDirs
+func (r *DirResult) WriteJSON(w io.Writer, actualDir, expectedDir string) error {
+	type jsonComparison struct {
+		Path  string  `json:"path"`
+		Score float64 `json:"score"`
+		Match bool    `json:"match"`
+		Error string  `json:"error,omitempty"`
+	}
+	type jsonReport struct {
+		Actual      string           `json:"actual"`
+		Expected    string           `json:"expected"`
+		Runtime     string           `json:"runtime"`
+		Passed      bool             `json:"passed"`
+		Compared    int              `json:"compared"`
+		Failed      int              `json:"failed"`
+		Missing     []string         `json:"missing"`
+		Extra       []string         `json:"extra"`
+		Comparisons []jsonComparison `json:"comparisons"`
+	}
+
+	report := jsonReport{
+		Actual:      actualDir,
+		Expected:    expectedDir,
+		Runtime:     r.Runtime.String(),
+		Passed:      r.Passed(),
+		Compared:    len(r.Comparisons),
+		Failed:      r.Failed,
+		Missing:     append([]string{}, r.Missing...),
+		Extra:       append([]string{}, r.Extra...),
+		Comparisons: make([]jsonComparison, 0, len(r.Comparisons)),
+	}
+	for _, c := range r.Comparisons {
+		entry := jsonComparison{Path: c.Name, Score: c.Score, Match: c.Match}
+		if c.Err != nil {
+			entry.Error = c.Err.Error()
+		}
+		report.Comparisons = append(report.Comparisons, entry)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
@@ -15,8 +15,29 @@ type Result struct {
 	// True, if the program did not finish within the timeframe given by Timeout
 	Timeout bool
 	// Score gives the percentage of pixels with difference (minus AdmissibleDiffPixel) between two images.
-	// Is a value between 0 (inclusively) and 1 (inclusively)
+	// Is a value between 0 (inclusively) and 1 (inclusively).
+	// If Config.Metrics is non-empty, Score is the weighted mean of the metric scores
 	Score float64
+	// Match is the combined verdict. It is true iff Score does not exceed Config.Threshold or,
+	// if Config.Metrics is non-empty, iff every metric's score does not exceed its threshold
+	Match bool
+	// BaseFormat gives the detected file format of the base image
+	BaseFormat string
+	// RefFormat gives the detected file format of the reference image
+	RefFormat string
+	// BaseBitDepth gives the bit depth of the source of the base image (zero means unknown)
+	BaseBitDepth int
+	// RefBitDepth gives the bit depth of the source of the reference image (zero means unknown)
+	RefBitDepth int
+	// Metrics gives the score of every metric of Config.Metrics (in the same order)
+	Metrics []MetricResult
+	// FrameScores gives the score of every frame of the base image (first index)
+	// compared with every frame of the reference image (second index). Set by CompareFrames only
+	FrameScores [][]float64
+	// Frames gives the aligned pairs of frames in order. Set by CompareFrames only
+	Frames []FrameResult
+	// BestFrame is the pair of frames with the lowest score. Set by CompareFrames only
+	BestFrame FrameResult
 
 	config Config
 }